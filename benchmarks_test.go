@@ -2,7 +2,10 @@ package rope
 
 import (
 	"bytes"
+	mrand "math/rand"
+	"sort"
 	"testing"
+	"time"
 )
 
 const benchBytesLen = 1 * 1024 * 1024
@@ -127,3 +130,157 @@ func BenchmarkIterRune(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkNewParallel(b *testing.B) {
+	bytes := getBenchBytes()
+	cfg := &Config[byte]{Workers: 4}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.SetBytes(benchBytesLen)
+		NewFromBytesWithConfig(bytes, cfg)
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	r := getBenchRope()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.SetBytes(benchBytesLen)
+		r.Count([]byte("foo"))
+	}
+}
+
+func BenchmarkCountParallel(b *testing.B) {
+	r := NewFromBytesWithConfig(getBenchBytes(), &Config[byte]{Workers: 4})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.SetBytes(benchBytesLen)
+		r.Count([]byte("foo"))
+	}
+}
+
+func BenchmarkBuilderAppend(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		builder := NewFromBytes(nil).Builder()
+		for j := 0; j < 4096; j++ {
+			builder.Append([]byte{'x'})
+		}
+		builder.Freeze()
+	}
+}
+
+func benchmarkIterFragmented(b *testing.B, r *Rope) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.SetBytes(int64(r.Len()))
+		r.Iter(0, func([]byte) bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkIterFragmented(b *testing.B) {
+	r := NewFromBytesWithConfig(getBenchBytes(), &Config[byte]{MaxLengthPerNode: 128, MinLengthPerNode: -1})
+	benchmarkIterFragmented(b, fragment(r, 20000))
+}
+
+func BenchmarkIterFragmentedMerged(b *testing.B) {
+	r := NewFromBytesWithConfig(getBenchBytes(), &Config[byte]{MaxLengthPerNode: 128})
+	benchmarkIterFragmented(b, fragment(r, 20000))
+}
+
+func BenchmarkIterCompacted(b *testing.B) {
+	r := NewFromBytesWithConfig(getBenchBytes(), &Config[byte]{MaxLengthPerNode: 128, MinLengthPerNode: -1})
+	benchmarkIterFragmented(b, fragment(r, 20000).Compact())
+}
+
+// benchmarkLatency runs op b.N times and reports the median, 99th percentile and maximum durations
+func benchmarkLatency(b *testing.B, op func(i int)) {
+	durations := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t0 := time.Now()
+		op(i)
+		durations[i] = time.Since(t0)
+	}
+	b.StopTimer()
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	b.ReportMetric(float64(durations[b.N/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(durations[b.N*99/100].Nanoseconds()), "p99-ns")
+	b.ReportMetric(float64(durations[b.N-1].Nanoseconds()), "max-ns")
+}
+
+func BenchmarkInsertLatency(b *testing.B) {
+	r := getBenchRope()
+	rnd := mrand.New(mrand.NewSource(42))
+	benchmarkLatency(b, func(int) {
+		r = r.Insert(rnd.Intn(r.Len()+1), []byte("x"))
+	})
+}
+
+func BenchmarkDeleteLatency(b *testing.B) {
+	r := getBenchRope()
+	rnd := mrand.New(mrand.NewSource(42))
+	benchmarkLatency(b, func(int) {
+		if r.Len() < 2 {
+			r = getBenchRope()
+		}
+		r = r.Delete(rnd.Intn(r.Len()-1), 1)
+	})
+}
+
+func BenchmarkSplitConcatLatency(b *testing.B) {
+	r := getBenchRope()
+	rnd := mrand.New(mrand.NewSource(42))
+	benchmarkLatency(b, func(int) {
+		r1, r2 := r.Split(rnd.Intn(r.Len() + 1))
+		r = r2.Concat(r1)
+	})
+}
+
+func BenchmarkAppendLatency(b *testing.B) {
+	r := NewFromBytes(nil)
+	benchmarkLatency(b, func(int) {
+		r = r.Concat(NewFromBytes([]byte("x")))
+	})
+}
+
+// getCJKRope returns a rope of about benchBytesLen bytes of Chinese text in lines of up to
+// a few hundred runes, mostly wide
+func getCJKRope() *Rope {
+	rnd := mrand.New(mrand.NewSource(42))
+	text := []rune("我能吞下玻璃而不伤身体，天地玄黄宇宙洪荒。ok ")
+	var bs []byte
+	for len(bs) < benchBytesLen {
+		for i := rnd.Intn(400); i > 0; i-- {
+			bs = append(bs, string(text[rnd.Intn(len(text))])...)
+		}
+		bs = append(bs, '\n')
+	}
+	return NewFromBytes(bs)
+}
+
+func BenchmarkVisualRowCountCJK(b *testing.B) {
+	r := getCJKRope()
+	rnd := mrand.New(mrand.NewSource(42))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r = r.Insert(r.NextGraphemeBoundary(rnd.Intn(r.Len())), []byte("x"))
+		r.VisualRowCount(80, 4)
+	}
+}
+
+func BenchmarkOffsetForVisualRowCJK(b *testing.B) {
+	r := getCJKRope()
+	rows := r.VisualRowCount(80, 4)
+	rnd := mrand.New(mrand.NewSource(42))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		off := r.OffsetForVisualRow(rnd.Intn(rows), 80, 4)
+		if r.VisualRow(off, 80, 4) < 0 {
+			b.Fatal()
+		}
+	}
+}
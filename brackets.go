@@ -0,0 +1,162 @@
+package rope
+
+// Bracket matching. Each kind of bracket is matched independently of the others,
+// and brackets in strings or comments are not told apart.
+
+var bracketPairs = [...][2]byte{
+	{'(', ')'},
+	{'[', ']'},
+	{'{', '}'},
+}
+
+// bracketKind returns the kind of b and whether it opens, kind is -1 if b is not a bracket
+func bracketKind(b byte) (kind int, open bool) {
+	for k, p := range bracketPairs {
+		switch b {
+		case p[0]:
+			return k, true
+		case p[1]:
+			return k, false
+		}
+	}
+	return -1, false
+}
+
+// bracketBalance holds the unmatched brackets of a piece of text
+type bracketBalance struct {
+	open  int
+	close int
+}
+
+type bracketSummary [len(bracketPairs)]bracketBalance
+
+type bracketMeasure struct{}
+
+func (bracketMeasure) Summarize(bs []byte) (s bracketSummary) {
+	for _, b := range bs {
+		k, open := bracketKind(b)
+		switch {
+		case k < 0:
+		case open:
+			s[k].open++
+		case s[k].open > 0:
+			s[k].open--
+		default:
+			s[k].close++
+		}
+	}
+	return
+}
+
+func (bracketMeasure) Combine(a, b bracketSummary) (s bracketSummary) {
+	for k := range s {
+		matched := min(a[k].open, b[k].close)
+		s[k].open = a[k].open - matched + b[k].open
+		s[k].close = a[k].close + b[k].close - matched
+	}
+	return
+}
+
+// matchForward returns the offset of the first unmatched closing bracket of kind k at or after from
+func (r *Rope) matchForward(offset, from, k int, depth *int) int {
+	if r == nil {
+		return -1
+	}
+	l := r.Len()
+	if offset+l <= from {
+		return -1
+	}
+	if offset >= from {
+		s := Summary(r, bracketMeasure{})[k]
+		if *depth-s.close >= 0 { // no unmatched close inside
+			*depth += s.open - s.close
+			return -1
+		}
+	}
+	if r.isLeaf() {
+		for i := max(from-offset, 0); i < len(r.content); i++ {
+			switch r.content[i] {
+			case bracketPairs[k][0]:
+				*depth++
+			case bracketPairs[k][1]:
+				*depth--
+				if *depth < 0 {
+					return offset + i
+				}
+			}
+		}
+		return -1
+	}
+	if i := r.left.matchForward(offset, from, k, depth); i >= 0 {
+		return i
+	}
+	return r.right.matchForward(offset+r.weight, from, k, depth)
+}
+
+// matchBackward returns the offset of the last unmatched opening bracket of kind k before to
+func (r *Rope) matchBackward(offset, to, k int, depth *int) int {
+	if r == nil || offset >= to {
+		return -1
+	}
+	l := r.Len()
+	if offset+l <= to {
+		s := Summary(r, bracketMeasure{})[k]
+		if *depth-s.open >= 0 { // no unmatched open inside
+			*depth += s.close - s.open
+			return -1
+		}
+	}
+	if r.isLeaf() {
+		for i := min(to-offset, len(r.content)) - 1; i >= 0; i-- {
+			switch r.content[i] {
+			case bracketPairs[k][1]:
+				*depth++
+			case bracketPairs[k][0]:
+				*depth--
+				if *depth < 0 {
+					return offset + i
+				}
+			}
+		}
+		return -1
+	}
+	if i := r.right.matchBackward(offset+r.weight, to, k, depth); i >= 0 {
+		return i
+	}
+	return r.left.matchBackward(offset, to, k, depth)
+}
+
+// MatchingBracket returns the offset of the bracket matching the one at offset, -1 if there is none.
+// (), [] and {} are recognized.
+func (r *Rope) MatchingBracket(offset int) int {
+	if offset < 0 || offset >= r.Len() {
+		return -1
+	}
+	k, open := bracketKind(r.Index(offset))
+	if k < 0 {
+		return -1
+	}
+	depth := 0
+	if open {
+		return r.matchForward(0, offset+1, k, &depth)
+	}
+	return r.matchBackward(0, offset, k, &depth)
+}
+
+// EnclosingBrackets returns the offsets of the innermost pair of brackets around offset.
+// open is -1 if there is none, close is -1 if open is not matched.
+func (r *Rope) EnclosingBrackets(offset int) (open, close int) {
+	open, close = -1, -1
+	kind := -1
+	for k := range bracketPairs {
+		depth := 0
+		if i := r.matchBackward(0, offset, k, &depth); i > open {
+			open, kind = i, k
+		}
+	}
+	if open >= 0 {
+		depth := 0
+		close = r.matchForward(0, max(offset, open+1), kind, &depth)
+	}
+	return
+}
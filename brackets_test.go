@@ -0,0 +1,55 @@
+package rope
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBrackets(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	alphabet := []byte("()[]{}ab\n")
+	bs := make([]byte, 2000)
+	for i := range bs {
+		bs[i] = alphabet[rnd.Intn(len(alphabet))]
+	}
+	bs = append(bytes.Repeat([]byte("{("), 100), append(bs, bytes.Repeat([]byte(")}"), 100)...)...)
+	r := NewFromBytes(bs)
+	// naive matching with a stack per kind
+	match := make([]int, len(bs))
+	var stacks [len(bracketPairs)][]int
+	for i, b := range bs {
+		match[i] = -1
+		k, open := bracketKind(b)
+		switch {
+		case k < 0:
+		case open:
+			stacks[k] = append(stacks[k], i)
+		case len(stacks[k]) > 0:
+			j := stacks[k][len(stacks[k])-1]
+			stacks[k] = stacks[k][:len(stacks[k])-1]
+			match[i], match[j] = j, i
+		}
+	}
+	for i := range bs {
+		if r.MatchingBracket(i) != match[i] {
+			t.Fatal(i)
+		}
+	}
+	for i := 0; i <= len(bs); i++ {
+		open, close := -1, -1
+		for j := i - 1; j >= 0; j-- {
+			if _, o := bracketKind(bs[j]); o && (match[j] < 0 || match[j] >= i) {
+				open, close = j, match[j]
+				break
+			}
+		}
+		o, c := r.EnclosingBrackets(i)
+		if o != open || c != close {
+			t.Fatal(i)
+		}
+	}
+	if r.MatchingBracket(-1) != -1 || r.MatchingBracket(len(bs)) != -1 {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,80 @@
+package rope
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Buffer holds the current version of a document shared by goroutines.
+// Readers take lock-free snapshots, writers replace the version with compare-and-swap.
+type Buffer struct {
+	current     atomic.Pointer[Rope]
+	mu          sync.Mutex
+	subscribers map[chan *Rope]struct{}
+}
+
+// NewBuffer returns a buffer holding r
+func NewBuffer(r *Rope) *Buffer {
+	if r == nil {
+		r = NewFromBytes(nil)
+	}
+	b := &Buffer{
+		subscribers: make(map[chan *Rope]struct{}),
+	}
+	b.current.Store(r)
+	return b
+}
+
+// Snapshot returns the current version
+func (b *Buffer) Snapshot() *Rope {
+	return b.current.Load()
+}
+
+// Update replaces the current version with fn applied to it and returns the new version.
+// fn is retried if another update happens in between, so it must not have side effects.
+func (b *Buffer) Update(fn func(*Rope) *Rope) *Rope {
+	for {
+		old := b.current.Load()
+		r := fn(old)
+		if r == old {
+			return r
+		}
+		if b.current.CompareAndSwap(old, r) {
+			b.notify()
+			return r
+		}
+	}
+}
+
+// notify sends the current version to the subscribers, replacing the ones not received yet
+func (b *Buffer) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.current.Load()
+	for c := range b.subscribers {
+		select {
+		case <-c:
+		default:
+		}
+		c <- r
+	}
+}
+
+// Subscribe returns a channel receiving the latest version after updates.
+// Versions not received before the next update are skipped.
+// The returned function cancels the subscription and closes the channel.
+func (b *Buffer) Subscribe() (<-chan *Rope, func()) {
+	c := make(chan *Rope, 1)
+	b.mu.Lock()
+	b.subscribers[c] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, c)
+			close(c)
+			b.mu.Unlock()
+		})
+	}
+}
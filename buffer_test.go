@@ -0,0 +1,83 @@
+package rope
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer(NewFromBytes([]byte("foo")))
+	c, cancel := b.Subscribe()
+	r := b.Update(func(r *Rope) *Rope {
+		return r.Insert(r.Len(), []byte("bar"))
+	})
+	if string(r.Bytes()) != "foobar" || b.Snapshot() != r {
+		t.Fatal()
+	}
+	if <-c != r {
+		t.Fatal()
+	}
+	if b.Update(func(r *Rope) *Rope {
+		return r
+	}) != r {
+		t.Fatal()
+	}
+	cancel()
+	cancel()
+	if _, ok := <-c; ok {
+		t.Fatal()
+	}
+}
+
+func TestBufferConcurrent(t *testing.T) {
+	b := NewBuffer(nil)
+	writers := 8
+	n := 200
+	c, cancel := b.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		last := 0
+		for r := range c {
+			if r.Len() < last {
+				t.Error()
+			}
+			last = r.Len()
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				b.Update(func(r *Rope) *Rope {
+					return r.Insert(r.Len()/2, []byte{'a' + byte(i)})
+				})
+			}
+		}()
+		go func() { // reader
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				r := b.Snapshot()
+				if len(r.Bytes()) != r.Len() {
+					t.Error()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	<-done
+	bs := b.Snapshot().Bytes()
+	if len(bs) != writers*n {
+		t.Fatal()
+	}
+	for i := 0; i < writers; i++ {
+		if bytes.Count(bs, []byte{'a' + byte(i)}) != n {
+			t.Fatal()
+		}
+	}
+}
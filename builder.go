@@ -0,0 +1,169 @@
+package rope
+
+// builderToken identifies the nodes a Builder may mutate until it is frozen
+type builderToken struct {
+	_ byte
+}
+
+// Builder is a transient rope for bulk edits. Nodes it creates are mutated in place by later edits,
+// nodes shared with other ropes are copied first, so ropes made before the builder are not affected.
+// A Builder must not be used by several goroutines at once.
+type Builder struct {
+	root  *Rope
+	token *builderToken
+}
+
+// Builder returns a builder starting with the content of r
+func (r *Rope) Builder() *Builder {
+	if r == nil {
+		r = NewFromBytes(nil)
+	}
+	return &Builder{
+		root:  r,
+		token: new(builderToken),
+	}
+}
+
+// Len returns the length of the content
+func (b *Builder) Len() int {
+	return b.root.Len()
+}
+
+// Freeze returns the content as an immutable rope. The builder may still be used,
+// the nodes of the returned rope are copied before later edits.
+func (b *Builder) Freeze() *Rope {
+	b.token = new(builderToken)
+	return b.root.mustValidate()
+}
+
+// own returns node if the builder owns it, an owned copy otherwise
+func (b *Builder) own(node *Rope) *Rope {
+	if node.owner == b.token {
+		return node
+	}
+	cfg := node.config()
+	ret := &Rope{
+		height: node.height,
+		weight: node.weight,
+		left:   node.left,
+		right:  node.right,
+		cfg:    cfg,
+		owner:  b.token,
+	}
+	if node.isLeaf() {
+		ret.content = cfg.append(cfg.alloc(cfg.MaxLengthPerNode), node.content...)
+	}
+	return ret
+}
+
+// leaf returns the path to the leaf containing offset n, preferring the end of a leaf if atEnd is set,
+// and whether the path went left at each node
+func (b *Builder) leaf(n int, atEnd bool) (path []*Rope, lefts []bool, i int) {
+	node := b.root
+	for !node.isLeaf() {
+		path = append(path, node)
+		if node.left != nil && (n < node.weight || atEnd && n == node.weight || node.right == nil) {
+			lefts = append(lefts, true)
+			node = node.left
+		} else {
+			lefts = append(lefts, false)
+			n -= node.weight
+			node = node.right
+		}
+	}
+	return append(path, node), lefts, n
+}
+
+// mutate makes the nodes of path owned, calls fn with the owned leaf and updates
+// the weights of the path for the length change delta
+func (b *Builder) mutate(path []*Rope, lefts []bool, delta int, fn func(leaf *Rope)) {
+	parent := (*Rope)(nil)
+	for k, node := range path {
+		node = b.own(node)
+		switch {
+		case parent == nil:
+			b.root = node
+		case lefts[k-1]:
+			parent.left = node
+		default:
+			parent.right = node
+		}
+		if k < len(lefts) && lefts[k] {
+			node.weight += delta
+		}
+		path[k] = node
+		parent = node
+	}
+	fn(path[len(path)-1])
+}
+
+// newLeaf returns an owned leaf holding a copy of bs
+func (b *Builder) newLeaf(bs []byte) *Rope {
+	cfg := b.root.config()
+	return &Rope{
+		height:  1,
+		weight:  len(bs),
+		content: cfg.append(cfg.alloc(cfg.MaxLengthPerNode), bs...),
+		cfg:     cfg,
+		owner:   b.token,
+	}
+}
+
+// Insert inserts bs at offset n
+func (b *Builder) Insert(n int, bs []byte) {
+	if len(bs) == 0 {
+		return
+	}
+	n = max(0, min(n, b.Len()))
+	cfg := b.root.config()
+	path, lefts, i := b.leaf(n, true)
+	if len(path[len(path)-1].content)+len(bs) <= cfg.MaxLengthPerNode {
+		b.mutate(path, lefts, len(bs), func(leaf *Rope) {
+			l := len(leaf.content)
+			leaf.content = cfg.append(leaf.content, bs...)
+			copy(leaf.content[i+len(bs):], leaf.content[i:l])
+			copy(leaf.content[i:], bs)
+			leaf.weight = len(leaf.content)
+			leaf.height = 1
+		})
+	} else {
+		var node *Rope
+		if len(bs) <= cfg.MaxLengthPerNode {
+			node = b.newLeaf(bs)
+		} else {
+			node = newFromBytes(bs, cfg)
+		}
+		if n == b.Len() {
+			b.root = b.root.Concat(node)
+		} else {
+			r1, r2 := b.root.Split(n)
+			b.root = r1.Concat(node).Concat(r2)
+		}
+	}
+	b.root.mustValidate()
+}
+
+// Append appends bs to the end
+func (b *Builder) Append(bs []byte) {
+	b.Insert(b.Len(), bs)
+}
+
+// Delete deletes l bytes at offset n
+func (b *Builder) Delete(n, l int) {
+	n = max(0, min(n, b.Len()))
+	l = min(l, b.Len()-n)
+	if l <= 0 {
+		return
+	}
+	path, lefts, i := b.leaf(n, false)
+	if c := path[len(path)-1].content; i+l < len(c) {
+		b.mutate(path, lefts, -l, func(leaf *Rope) {
+			copy(leaf.content[i:], leaf.content[i+l:])
+			leaf.content = leaf.content[:len(leaf.content)-l]
+			leaf.weight = len(leaf.content)
+		})
+	} else {
+		b.root = b.root.Delete(n, l)
+	}
+	b.root.mustValidate()
+}
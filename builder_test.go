@@ -0,0 +1,66 @@
+package rope
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	bs := bytes.Repeat([]byte("foobarbaz"), 32)
+	base := NewFromBytes(bs)
+	b := base.Builder()
+	type version struct {
+		r  *Rope
+		bs []byte
+	}
+	versions := []version{{base, append([]byte(nil), bs...)}}
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 5000; i++ {
+		n := rnd.Intn(len(bs) + 1)
+		switch rnd.Intn(4) {
+		case 0:
+			text := bytes.Repeat([]byte{byte('a' + i%26)}, rnd.Intn(12))
+			b.Insert(n, text)
+			bs = append(bs[:n:n], append(text, bs[n:]...)...)
+		case 1:
+			text := bytes.Repeat([]byte{byte('a' + i%26)}, rnd.Intn(3))
+			b.Append(text)
+			bs = append(bs, text...)
+		case 2:
+			l := rnd.Intn(len(bs)-n+1) / 8
+			b.Delete(n, l)
+			bs = append(bs[:n:n], bs[n+l:]...)
+		case 3:
+			if rnd.Intn(10) == 0 {
+				versions = append(versions, version{b.Freeze(), append([]byte(nil), bs...)})
+			}
+		}
+		if b.Len() != len(bs) {
+			t.Fatal()
+		}
+	}
+	r := b.Freeze()
+	if !bytes.Equal(r.Bytes(), bs) {
+		t.Fatal()
+	}
+	for _, v := range versions {
+		if !bytes.Equal(v.r.Bytes(), v.bs) {
+			t.Fatal()
+		}
+	}
+	b = NewFromBytes(nil).Builder()
+	for i := 0; i < 1024; i++ {
+		b.Append([]byte{'x'})
+	}
+	leaves := 0
+	b.Freeze().iterNodes(func(n *Rope) bool {
+		if n.isLeaf() {
+			leaves++
+		}
+		return true
+	})
+	if leaves != 1024/defaultConfig.MaxLengthPerNode {
+		t.Fatal(leaves)
+	}
+}
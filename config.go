@@ -0,0 +1,63 @@
+package rope
+
+// Config holds the parameters of a rope. It is copied when the rope is constructed and
+// the copy is shared by all the nodes derived from it, so later changes do not affect the rope.
+// Zero fields take the default values.
+type Config[T any] struct {
+	// MaxLengthPerNode is the length of full leaves
+	MaxLengthPerNode int
+	// MinLengthPerNode is the length below which Concat merges a leaf with its neighbour,
+	// MaxLengthPerNode/4 if zero, merging is disabled if it is negative
+	MinLengthPerNode int
+	// Alloc returns a buffer of length n for the content of new leaves, make is used if nil.
+	// Only byte ropes use it, for the leaves filled by Append, Prepend, Concat and Builder:
+	// the constructors and the other rope types keep slices of the content they are given.
+	Alloc func(n int) []T
+	// Workers is the number of goroutines used by bulk operations on large ropes,
+	// they run on the calling goroutine if it is less than 2
+	Workers int
+}
+
+var (
+	defaultConfig         = &Config[byte]{MaxLengthPerNode: 128}
+	defaultRuneConfig     = &Config[rune]{MaxLengthPerNode: 128}
+	defaultRopeConfig     = &Config[Rope]{MaxLengthPerNode: 512}
+	defaultRuneRopeConfig = &Config[RuneRope]{MaxLengthPerNode: 512}
+)
+
+// normalize returns a copy of c with the zero fields taken from def
+func (c *Config[T]) normalize(def *Config[T]) *Config[T] {
+	if c == nil {
+		return def
+	}
+	ret := *c
+	if ret.MaxLengthPerNode <= 0 {
+		ret.MaxLengthPerNode = def.MaxLengthPerNode
+	}
+	return &ret
+}
+
+// minLength returns the length below which leaves are merged
+func (c *Config[T]) minLength() int {
+	if c.MinLengthPerNode == 0 {
+		return c.MaxLengthPerNode / 4
+	}
+	return c.MinLengthPerNode
+}
+
+// alloc returns an empty buffer with capacity n, capped so that appending never writes past
+// the buffer Alloc returned
+func (c *Config[T]) alloc(n int) []T {
+	if c.Alloc != nil {
+		return c.Alloc(n)[:0:n]
+	}
+	return make([]T, 0, n)
+}
+
+// append appends elems to buf, growing it with Alloc
+func (c *Config[T]) append(buf []T, elems ...T) []T {
+	if len(buf)+len(elems) > cap(buf) {
+		buf = append(c.alloc(2*cap(buf)+len(elems)), buf...)
+	}
+	return append(buf, elems...)
+}
@@ -0,0 +1,80 @@
+package rope
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	bs := bytes.Repeat([]byte("foobarbaz"), 64)
+	var allocs int64
+	cfg := &Config[byte]{
+		MaxLengthPerNode: 32,
+		Alloc: func(n int) []byte {
+			atomic.AddInt64(&allocs, 1)
+			return make([]byte, n)
+		},
+	}
+	var wg sync.WaitGroup
+	for _, c := range []*Config[byte]{nil, cfg, {MaxLengthPerNode: 3}, {MaxLengthPerNode: 100}} {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			maxLength := c.normalize(defaultConfig).MaxLengthPerNode
+			r := NewFromBytesWithConfig(bs, c)
+			for i := 0; i < 256; i++ {
+				r = r.Insert(i*3, []byte("x"))
+				r = r.Concat(NewFromBytesWithConfig([]byte("y"), c))
+			}
+			r, _ = r.Split(r.Len() - 1)
+			r.iterNodes(func(n *Rope) bool {
+				if len(n.content) > maxLength {
+					t.Error()
+				}
+				if n.config().MaxLengthPerNode != maxLength {
+					t.Error()
+				}
+				return true
+			})
+			if r.Len() != len(bs)+511 {
+				t.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	if atomic.LoadInt64(&allocs) == 0 {
+		t.Fatal()
+	}
+
+	rr := NewFromRunesWithConfig([]rune("foobarbaz"), &Config[rune]{MaxLengthPerNode: 2})
+	if rr.left == nil || len(rr.left.content) > 2 || string(rr.Insert(3, []rune("我")).Runes()) != "foo我barbaz" {
+		t.Fatal()
+	}
+}
+
+func TestConfigCopied(t *testing.T) {
+	cfg := &Config[byte]{MaxLengthPerNode: 4}
+	r := NewFromBytesWithConfig([]byte("foobarbaz"), cfg)
+	cfg.MaxLengthPerNode = 2
+	cfg.MinLengthPerNode = -1
+	r = r.Insert(3, []byte("quux")).Append([]byte("xyzzy"))
+	r.iterNodes(func(n *Rope) bool {
+		if len(n.content) > 4 || n.config() == cfg || n.config().MaxLengthPerNode != 4 {
+			t.Fatal()
+		}
+		return true
+	})
+	if string(r.Bytes()) != "fooquuxbarbazxyzzy" {
+		t.Fatal()
+	}
+
+	rcfg := &Config[Rope]{MaxLengthPerNode: 2}
+	rr := NewFromRopeWithConfig([]Rope{*NewFromBytes([]byte("a")), *NewFromBytes([]byte("b")), *NewFromBytes([]byte("c"))}, rcfg)
+	rcfg.MaxLengthPerNode = 1
+	if rr.config() == rcfg || rr.Insert(1, []Rope{*NewFromBytes([]byte("x"))}).config().MaxLengthPerNode != 2 {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,6 @@
+//go:build ropedebug
+
+package rope
+
+// debug validates ropes after every mutating operation
+const debug = true
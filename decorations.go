@@ -0,0 +1,376 @@
+package rope
+
+import "math/rand"
+
+// Decoration is a value attached to the bytes from Start to End
+type Decoration[V any] struct {
+	Start int
+	End   int
+	Value V
+}
+
+// DecorationID identifies a decoration in a Decorations set
+type DecorationID int
+
+// Decorations is a set of byte ranges of a rope that follow its edits, like highlights or diagnostics.
+// Ranges are kept in a treap ordered by start and augmented with the maximum end of each subtree,
+// so overlap queries and edits only visit the ranges involved.
+// Text inserted at the boundary of a range is not included in it.
+// Ranges whose text is deleted entirely are removed, empty ranges collapse to the start of the deletion.
+type Decorations[V any] struct {
+	rope   *Rope
+	root   *decorationNode[V]
+	nodes  map[DecorationID]*decorationNode[V]
+	nextID DecorationID
+	rand   *rand.Rand
+}
+
+type decorationNode[V any] struct {
+	id       DecorationID
+	start    int
+	end      int
+	maxEnd   int // maximum end in the subtree
+	add      int // pending shift of the subtree, this node included
+	value    V
+	priority uint32
+	left     *decorationNode[V]
+	right    *decorationNode[V]
+	parent   *decorationNode[V]
+}
+
+// NewDecorations returns an empty decoration set for r
+func NewDecorations[V any](r *Rope) *Decorations[V] {
+	if r == nil {
+		r = NewFromBytes(nil)
+	}
+	return &Decorations[V]{
+		rope:  r,
+		nodes: make(map[DecorationID]*decorationNode[V]),
+		rand:  rand.New(rand.NewSource(1)),
+	}
+}
+
+// Rope returns the version the decorations refer to
+func (d *Decorations[V]) Rope() *Rope {
+	return d.rope
+}
+
+// Len returns the number of decorations
+func (d *Decorations[V]) Len() int {
+	return len(d.nodes)
+}
+
+func (n *decorationNode[V]) push() {
+	if n.add == 0 {
+		return
+	}
+	n.start += n.add
+	n.end += n.add
+	n.maxEnd += n.add
+	if n.left != nil {
+		n.left.add += n.add
+	}
+	if n.right != nil {
+		n.right.add += n.add
+	}
+	n.add = 0
+}
+
+// update recomputes maxEnd, n must have no pending shift
+func (n *decorationNode[V]) update() {
+	n.maxEnd = n.end
+	if n.left != nil && n.left.maxEnd+n.left.add > n.maxEnd {
+		n.maxEnd = n.left.maxEnd + n.left.add
+	}
+	if n.right != nil && n.right.maxEnd+n.right.add > n.maxEnd {
+		n.maxEnd = n.right.maxEnd + n.right.add
+	}
+}
+
+func (n *decorationNode[V]) setChildren(left, right *decorationNode[V]) {
+	n.left, n.right = left, right
+	if left != nil {
+		left.parent = n
+	}
+	if right != nil {
+		right.parent = n
+	}
+	n.update()
+}
+
+// splitDecorations splits t into the nodes satisfying less and the rest, less must be monotonic in order
+func splitDecorations[V any](t *decorationNode[V], less func(*decorationNode[V]) bool) (l, r *decorationNode[V]) {
+	if t == nil {
+		return
+	}
+	t.push()
+	t.parent = nil
+	if less(t) {
+		a, b := splitDecorations(t.right, less)
+		t.setChildren(t.left, a)
+		if b != nil {
+			b.parent = nil
+		}
+		return t, b
+	}
+	a, b := splitDecorations(t.left, less)
+	t.setChildren(b, t.right)
+	if a != nil {
+		a.parent = nil
+	}
+	return a, t
+}
+
+func mergeDecorations[V any](l, r *decorationNode[V]) *decorationNode[V] {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.priority > r.priority {
+		l.push()
+		l.setChildren(l.left, mergeDecorations(l.right, r))
+		return l
+	}
+	r.push()
+	r.setChildren(mergeDecorations(l, r.left), r.right)
+	return r
+}
+
+func startBefore[V any](offset int) func(*decorationNode[V]) bool {
+	return func(n *decorationNode[V]) bool {
+		return n.start < offset
+	}
+}
+
+func (d *Decorations[V]) setRoot(root *decorationNode[V]) {
+	d.root = root
+	if root != nil {
+		root.parent = nil
+	}
+}
+
+func (d *Decorations[V]) insertNode(n *decorationNode[V]) {
+	n.update()
+	l, r := splitDecorations(d.root, startBefore[V](n.start+1))
+	d.setRoot(mergeDecorations(mergeDecorations(l, n), r))
+}
+
+func (d *Decorations[V]) clamp(offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	if l := d.rope.Len(); offset > l {
+		return l
+	}
+	return offset
+}
+
+// Add adds a decoration from start to end
+func (d *Decorations[V]) Add(start, end int, value V) DecorationID {
+	start, end = d.clamp(start), d.clamp(end)
+	if end < start {
+		end = start
+	}
+	d.nextID++
+	n := &decorationNode[V]{
+		id:       d.nextID,
+		start:    start,
+		end:      end,
+		value:    value,
+		priority: d.rand.Uint32(),
+	}
+	d.nodes[n.id] = n
+	d.insertNode(n)
+	return n.id
+}
+
+// Get returns a decoration, false if it was removed
+func (d *Decorations[V]) Get(id DecorationID) (ret Decoration[V], ok bool) {
+	n, ok := d.nodes[id]
+	if !ok {
+		return
+	}
+	shift := 0
+	for p := n; p != nil; p = p.parent {
+		shift += p.add
+	}
+	return Decoration[V]{n.start + shift, n.end + shift, n.value}, true
+}
+
+// Remove removes a decoration
+func (d *Decorations[V]) Remove(id DecorationID) {
+	n, ok := d.nodes[id]
+	if !ok {
+		return
+	}
+	delete(d.nodes, id)
+	// apply the pending shifts above n
+	var path []*decorationNode[V]
+	for p := n; p != nil; p = p.parent {
+		path = append(path, p)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].push()
+	}
+	c := mergeDecorations(n.left, n.right)
+	p := n.parent
+	if p == nil {
+		d.setRoot(c)
+		return
+	}
+	if p.left == n {
+		p.setChildren(c, p.right)
+	} else {
+		p.setChildren(p.left, c)
+	}
+	for p = p.parent; p != nil; p = p.parent {
+		p.update()
+	}
+}
+
+// Query calls fn with the decorations overlapping the bytes from start to end in increasing start order.
+// Empty decorations overlap if they are inside the range.
+func (d *Decorations[V]) Query(start, end int, fn func(id DecorationID, dec Decoration[V]) bool) {
+	var query func(n *decorationNode[V]) bool
+	query = func(n *decorationNode[V]) bool {
+		if n == nil {
+			return true
+		}
+		n.push()
+		if n.maxEnd < start {
+			return true
+		}
+		if !query(n.left) {
+			return false
+		}
+		if n.start >= end {
+			return true
+		}
+		if n.end > start || n.start >= start {
+			if !fn(n.id, Decoration[V]{n.start, n.end, n.value}) {
+				return false
+			}
+		}
+		return query(n.right)
+	}
+	query(d.root)
+}
+
+// ReplaceRange removes the decorations starting from start to end and adds decs,
+// for rebuilding the decorations of a changed region
+func (d *Decorations[V]) ReplaceRange(start, end int, decs []Decoration[V]) []DecorationID {
+	a, rest := splitDecorations(d.root, startBefore[V](start))
+	mid, c := splitDecorations(rest, startBefore[V](end))
+	var remove func(n *decorationNode[V])
+	remove = func(n *decorationNode[V]) {
+		if n != nil {
+			delete(d.nodes, n.id)
+			remove(n.left)
+			remove(n.right)
+		}
+	}
+	remove(mid)
+	d.setRoot(mergeDecorations(a, c))
+	ids := make([]DecorationID, 0, len(decs))
+	for _, dec := range decs {
+		ids = append(ids, d.Add(dec.Start, dec.End, dec.Value))
+	}
+	return ids
+}
+
+// fixEnds applies fn to the ends of the nodes of t ending after offset
+func fixEnds[V any](t *decorationNode[V], offset int, fn func(int) int) {
+	if t == nil {
+		return
+	}
+	t.push()
+	if t.maxEnd <= offset {
+		return
+	}
+	fixEnds(t.left, offset, fn)
+	fixEnds(t.right, offset, fn)
+	if t.end > offset {
+		t.end = fn(t.end)
+	}
+	t.update()
+}
+
+// Insert inserts bs at n in the rope and moves the decorations after it
+func (d *Decorations[V]) Insert(n int, bs []byte) {
+	n = d.clamp(n)
+	d.rope = d.rope.Insert(n, bs)
+	l := len(bs)
+	if l == 0 {
+		return
+	}
+	a, b := splitDecorations(d.root, startBefore[V](n))
+	// ranges containing n grow
+	fixEnds(a, n, func(end int) int {
+		return end + l
+	})
+	if b != nil {
+		b.add += l
+	}
+	d.setRoot(mergeDecorations(a, b))
+}
+
+// Delete deletes l bytes at n in the rope, shrinking the decorations overlapping the range
+func (d *Decorations[V]) Delete(n, l int) {
+	n = d.clamp(n)
+	if n+l > d.rope.Len() {
+		l = d.rope.Len() - n
+	}
+	if l <= 0 {
+		return
+	}
+	d.rope = d.rope.Delete(n, l)
+	a, rest := splitDecorations(d.root, startBefore[V](n))
+	mid, c := splitDecorations(rest, startBefore[V](n+l+1))
+	shrink := func(end int) int {
+		if end < n+l {
+			return n
+		}
+		return end - l
+	}
+	fixEnds(a, n, shrink)
+	if c != nil {
+		c.add -= l
+	}
+	// the ranges starting inside the deletion now start at n
+	var nodes []*decorationNode[V]
+	var collect func(t *decorationNode[V])
+	collect = func(t *decorationNode[V]) {
+		if t == nil {
+			return
+		}
+		t.push()
+		left, right := t.left, t.right
+		t.left, t.right, t.parent = nil, nil, nil
+		collect(left)
+		empty := t.start == t.end
+		t.start, t.end = n, shrink(t.end)
+		if t.start == t.end && !empty {
+			delete(d.nodes, t.id)
+		} else {
+			t.update()
+			nodes = append(nodes, t)
+		}
+		collect(right)
+	}
+	collect(mid)
+	for _, t := range nodes {
+		a = mergeDecorations(a, t)
+	}
+	d.setRoot(mergeDecorations(a, c))
+}
+
+// ApplyEdits applies edits to the rope and moves the decorations, see Rope.ApplyEdits
+func (d *Decorations[V]) ApplyEdits(edits []Edit) {
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		d.Delete(e.Offset, e.Length)
+		d.Insert(e.Offset, e.Text)
+	}
+}
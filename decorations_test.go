@@ -0,0 +1,125 @@
+package rope
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDecorations(t *testing.T) {
+	type span struct {
+		start, end int
+		removed    bool
+	}
+	bs := bytes.Repeat([]byte("foobarbaz"), 32)
+	d := NewDecorations[int](NewFromBytes(bs))
+	expected := make(map[DecorationID]*span)
+	rnd := rand.New(rand.NewSource(42))
+	check := func() {
+		if !bytes.Equal(d.Rope().Bytes(), bs) {
+			t.Fatal()
+		}
+		n := 0
+		for id, e := range expected {
+			dec, ok := d.Get(id)
+			if ok == e.removed {
+				t.Fatal()
+			}
+			if !ok {
+				continue
+			}
+			n++
+			if dec.Start != e.start || dec.End != e.end || dec.Value != int(id) {
+				t.Fatalf("%d %v %v", id, dec, e)
+			}
+		}
+		if d.Len() != n {
+			t.Fatal()
+		}
+		a := rnd.Intn(len(bs) + 1)
+		b := a + rnd.Intn(len(bs)-a+1)
+		n = 0
+		for _, e := range expected {
+			if !e.removed && e.start < b && (e.end > a || e.start >= a) {
+				n++
+			}
+		}
+		last := 0
+		d.Query(a, b, func(id DecorationID, dec Decoration[int]) bool {
+			n--
+			if dec.Start < last || dec.Start >= b || dec.End <= a && dec.Start < a {
+				t.Fatal()
+			}
+			last = dec.Start
+			return true
+		})
+		if n != 0 {
+			t.Fatal()
+		}
+	}
+	insert := func(n int, text []byte) {
+		bs = append(bs[:n:n], append(text, bs[n:]...)...)
+		for _, e := range expected {
+			if e.start >= n {
+				e.start += len(text)
+				e.end += len(text)
+			} else if e.end > n {
+				e.end += len(text)
+			}
+		}
+	}
+	del := func(n, l int) {
+		bs = append(bs[:n:n], bs[n+l:]...)
+		shrink := func(i int) int {
+			if i >= n+l {
+				return i - l
+			}
+			if i > n {
+				return n
+			}
+			return i
+		}
+		for _, e := range expected {
+			empty := e.start == e.end
+			e.start, e.end = shrink(e.start), shrink(e.end)
+			if e.start == e.end && !empty {
+				e.removed = true
+			}
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		n := rnd.Intn(len(bs) + 1)
+		switch rnd.Intn(6) {
+		case 0, 1:
+			e := &span{start: n, end: n + rnd.Intn(len(bs)-n+1)/4}
+			id := DecorationID(int(d.nextID) + 1)
+			if d.Add(e.start, e.end, int(id)) != id {
+				t.Fatal()
+			}
+			expected[id] = e
+		case 2:
+			text := bytes.Repeat([]byte("x"), rnd.Intn(5))
+			d.Insert(n, text)
+			insert(n, text)
+		case 3:
+			l := rnd.Intn(len(bs)-n+1) / 8
+			d.Delete(n, l)
+			del(n, l)
+		case 4:
+			if id := DecorationID(rnd.Intn(int(d.nextID) + 1)); expected[id] != nil {
+				d.Remove(id)
+				expected[id].removed = true
+			}
+		case 5:
+			end := n + rnd.Intn(len(bs)-n+1)/8
+			for _, e := range expected {
+				if e.start >= n && e.start < end {
+					e.removed = true
+				}
+			}
+			ids := d.ReplaceRange(n, end, []Decoration[int]{{n, end, int(d.nextID) + 1}})
+			expected[ids[0]] = &span{start: n, end: end}
+		}
+		check()
+	}
+}
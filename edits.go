@@ -0,0 +1,23 @@
+package rope
+
+// Edit replaces Length bytes at Offset with Text
+type Edit struct {
+	Offset int
+	Length int
+	Text   []byte
+}
+
+// ApplyEdits applies edits whose offsets refer to r.
+// The edits must be sorted by offset and not overlap.
+func (r *Rope) ApplyEdits(edits []Edit) *Rope {
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.Length > 0 {
+			r = r.Delete(e.Offset, e.Length)
+		}
+		if len(e.Text) > 0 {
+			r = r.Insert(e.Offset, e.Text)
+		}
+	}
+	return r
+}
@@ -0,0 +1,286 @@
+package rope
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a character encoding ropes are read from and written to, ropes holding UTF-8
+type Encoding int
+
+const (
+	EncodingUTF8 Encoding = iota
+	EncodingUTF16LE
+	EncodingUTF16BE
+	EncodingLatin1
+	EncodingWindows1252
+)
+
+// ErrUnrepresentable is returned when writing a character the encoding has no code for
+var ErrUnrepresentable = errors.New("rope: character not representable in encoding")
+
+// TruncatedError is returned with the rope read from UTF-16 input ending with an odd byte,
+// which is decoded as U+FFFD. It wraps io.ErrUnexpectedEOF.
+type TruncatedError struct {
+	Offset int64 // of the odd byte in the input
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("rope: UTF-16 input truncated at byte %d", e.Offset)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+const transcodeChunkSize = 64 * 1024
+
+// windows1252 maps bytes 0x80 to 0x9F, the undefined ones to the C1 controls of the same value
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+var windows1252Bytes = func() map[rune]byte {
+	m := make(map[rune]byte, len(windows1252))
+	for i, c := range windows1252 {
+		m[c] = byte(0x80 + i)
+	}
+	return m
+}()
+
+var byteOrderMarks = []struct {
+	bom []byte
+	enc Encoding
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, EncodingUTF8},
+	{[]byte{0xFF, 0xFE}, EncodingUTF16LE},
+	{[]byte{0xFE, 0xFF}, EncodingUTF16BE},
+}
+
+// appendSurrogate appends an unpaired UTF-16 surrogate in the generalized UTF-8 form (WTF-8),
+// which is not valid UTF-8 but is restored when writing UTF-16
+func appendSurrogate(dst []byte, u uint16) []byte {
+	return append(dst, 0xE0|byte(u>>12), 0x80|byte(u>>6)&0x3F, 0x80|byte(u)&0x3F)
+}
+
+// decodeChunk appends the UTF-8 form of src in enc to dst, returning the bytes of src consumed.
+// Incomplete sequences at the end of src are left unless eof is set.
+func decodeChunk(dst, src []byte, enc Encoding, eof bool) ([]byte, int) {
+	switch enc {
+	case EncodingLatin1:
+		for _, b := range src {
+			dst = utf8.AppendRune(dst, rune(b))
+		}
+		return dst, len(src)
+	case EncodingWindows1252:
+		for _, b := range src {
+			if b >= 0x80 && b < 0xA0 {
+				dst = utf8.AppendRune(dst, windows1252[b-0x80])
+			} else {
+				dst = utf8.AppendRune(dst, rune(b))
+			}
+		}
+		return dst, len(src)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		unit := func(i int) uint16 {
+			if enc == EncodingUTF16LE {
+				return uint16(src[i]) | uint16(src[i+1])<<8
+			}
+			return uint16(src[i])<<8 | uint16(src[i+1])
+		}
+		i := 0
+		for ; i+1 < len(src); i += 2 {
+			u := unit(i)
+			switch {
+			case !utf16.IsSurrogate(rune(u)):
+				dst = utf8.AppendRune(dst, rune(u))
+			case u < 0xDC00 && i+3 < len(src) && unit(i+2) >= 0xDC00 && unit(i+2) < 0xE000:
+				dst = utf8.AppendRune(dst, utf16.DecodeRune(rune(u), rune(unit(i+2))))
+				i += 2
+			case u < 0xDC00 && i+3 >= len(src) && !eof: // the low surrogate is in the next chunk
+				return dst, i
+			default:
+				dst = appendSurrogate(dst, u)
+			}
+		}
+		return dst, i
+	}
+	return append(dst, src...), len(src)
+}
+
+// NewFromReaderWithEncoding reads text in enc and returns it as a UTF-8 rope.
+// A byte order mark at the start overrides enc and is kept as U+FEFF, the returned encoding
+// writes the input back unchanged. Unpaired UTF-16 surrogates are kept in their generalized
+// UTF-8 form. A trailing odd byte of UTF-16 input becomes U+FFFD and a *TruncatedError is
+// returned with the rope.
+func NewFromReaderWithEncoding(rd io.Reader, enc Encoding) (*Rope, Encoding, error) {
+	buf := make([]byte, transcodeChunkSize)
+	n, err := io.ReadAtLeast(rd, buf[:3], 3)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return nil, enc, err
+	}
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(buf[:n], m.bom) {
+			enc = m.enc
+			break
+		}
+	}
+	ret := NewFromBytes(nil)
+	pending := n
+	var read int64 // input bytes decoded
+	for {
+		var m int
+		var err error
+		if pending < len(buf) {
+			m, err = rd.Read(buf[pending:])
+		}
+		if err != nil && err != io.EOF {
+			return nil, enc, err
+		}
+		eof := err == io.EOF || n < 3
+		src := buf[:pending+m]
+		dst, consumed := decodeChunk(make([]byte, 0, len(src)*3/2), src, enc, eof)
+		ret = ret.Concat(NewFromBytes(dst))
+		pending = copy(buf, src[consumed:])
+		read += int64(consumed)
+		if eof {
+			if pending > 0 {
+				return ret.Concat(NewFromBytes([]byte("\uFFFD"))), enc, &TruncatedError{read}
+			}
+			return ret, enc, nil
+		}
+	}
+}
+
+// nextRune returns the first character of bs, unpaired surrogates in generalized UTF-8 included.
+// size is 0 if more bytes are needed, ok is false for invalid bytes.
+func nextRune(bs []byte, eof bool) (c rune, size int, ok bool) {
+	need := 1
+	switch b := bs[0]; {
+	case b >= 0xF0:
+		need = 4
+	case b >= 0xE0:
+		need = 3
+	case b >= 0xC0:
+		need = 2
+	}
+	if len(bs) < need && !eof {
+		return 0, 0, false
+	}
+	if len(bs) >= 3 && bs[0] == 0xED && bs[1] >= 0xA0 && bs[1] <= 0xBF && bs[2]&0xC0 == 0x80 {
+		return rune(bs[1]&0x3F)<<6 | rune(bs[2]&0x3F) | 0xD000, 3, true
+	}
+	c, size = utf8.DecodeRune(bs)
+	return c, size, c != utf8.RuneError || size > 1
+}
+
+// encodeRune appends c in enc to dst
+func encodeRune(dst []byte, c rune, enc Encoding) ([]byte, bool) {
+	switch enc {
+	case EncodingLatin1:
+		if c > 0xFF {
+			return dst, false
+		}
+		return append(dst, byte(c)), true
+	case EncodingWindows1252:
+		if b, ok := windows1252Bytes[c]; ok {
+			return append(dst, b), true
+		}
+		if c > 0xFF || c >= 0x80 && c < 0xA0 {
+			return dst, false
+		}
+		return append(dst, byte(c)), true
+	case EncodingUTF16LE, EncodingUTF16BE:
+		units := []rune{c}
+		if c > 0xFFFF {
+			r1, r2 := utf16.EncodeRune(c)
+			units = []rune{r1, r2}
+		}
+		for _, u := range units {
+			if enc == EncodingUTF16LE {
+				dst = append(dst, byte(u), byte(u>>8))
+			} else {
+				dst = append(dst, byte(u>>8), byte(u))
+			}
+		}
+		return dst, true
+	}
+	return utf8.AppendRune(dst, c), true
+}
+
+// WriteToWithEncoding writes the rope to w in enc, see NewFromReaderWithEncoding.
+// ErrUnrepresentable is returned for characters enc can not encode and for invalid UTF-8
+// when enc is not EncodingUTF8.
+func (r *Rope) WriteToWithEncoding(w io.Writer, enc Encoding) (n int64, err error) {
+	write := func(bs []byte) bool {
+		var m int
+		m, err = w.Write(bs)
+		n += int64(m)
+		return err == nil
+	}
+	if enc == EncodingUTF8 {
+		r.Iter(0, write)
+		return
+	}
+	var carry []byte // an incomplete character at the end of the last leaf
+	dst := make([]byte, 0, transcodeChunkSize)
+	encode := func(bs []byte, eof bool) bool {
+		for len(bs) > 0 {
+			c, size, ok := nextRune(bs, eof)
+			if size == 0 {
+				carry = append(carry[:0], bs...)
+				break
+			}
+			if ok {
+				dst, ok = encodeRune(dst, c, enc)
+			}
+			if !ok {
+				err = ErrUnrepresentable
+				return false
+			}
+			bs = bs[size:]
+		}
+		if len(dst) >= transcodeChunkSize || eof {
+			if !write(dst) {
+				return false
+			}
+			dst = dst[:0]
+		}
+		return true
+	}
+	if !r.Iter(0, func(bs []byte) bool {
+		if len(carry) > 0 {
+			// complete the carried character with the start of bs
+			joined := append(carry, bs[:min(len(bs), utf8.UTFMax)]...)
+			carry = carry[:0]
+			c, size, ok := nextRune(joined, false)
+			if size == 0 { // still incomplete
+				carry = joined
+				return true
+			}
+			if ok {
+				dst, ok = encodeRune(dst, c, enc)
+			}
+			if !ok {
+				err = ErrUnrepresentable
+				return false
+			}
+			bs = bs[size-(len(joined)-min(len(bs), utf8.UTFMax)):]
+		}
+		return encode(bs, false)
+	}) {
+		return
+	}
+	encode(carry, true)
+	return
+}
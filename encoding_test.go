@@ -0,0 +1,89 @@
+package rope
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	units := []uint16{'a', 0xFEFF + 1, 0xD800, 0xDC00, 0xD83D, 0xDE00, 0x4E2D, 0xDBFF, 0xDFFF, 0}
+	var utf16Input []byte
+	for i := 0; i < 1000; i++ {
+		u := units[rnd.Intn(len(units))]
+		utf16Input = append(utf16Input, byte(u), byte(u>>8))
+	}
+	for _, c := range []struct {
+		input []byte
+		enc   Encoding
+	}{
+		{all, EncodingLatin1},
+		{all, EncodingWindows1252},
+		{bytes.Repeat(all, 10), EncodingUTF8},
+		{utf16Input, EncodingUTF16LE},
+		{append([]byte{0xFF, 0xFE}, utf16Input...), EncodingUTF8},
+		{append([]byte{0xFE, 0xFF}, 0, 'a', 0xD8, 0x3D, 0xDE, 0x00), EncodingUTF8},
+		{[]byte{}, EncodingUTF16BE},
+	} {
+		for _, rd := range []io.Reader{bytes.NewReader(c.input), iotest.OneByteReader(bytes.NewReader(c.input))} {
+			r, enc, err := NewFromReaderWithEncoding(rd, c.enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := new(bytes.Buffer)
+			n, err := r.WriteToWithEncoding(buf, enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(c.input)) || !bytes.Equal(buf.Bytes(), c.input) {
+				t.Fatal()
+			}
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	r, enc, err := NewFromReaderWithEncoding(bytes.NewReader([]byte{0x80, 0xE9, 0x93}), EncodingWindows1252)
+	if err != nil || enc != EncodingWindows1252 || string(r.Bytes()) != "€é“" {
+		t.Fatal()
+	}
+	var input []byte
+	for _, u := range utf16.Encode([]rune("\uFEFFfoo 中文 😀")) {
+		input = append(input, byte(u>>8), byte(u))
+	}
+	r, enc, err = NewFromReaderWithEncoding(bytes.NewReader(input), EncodingLatin1)
+	if err != nil || enc != EncodingUTF16BE || string(r.Bytes()) != "\uFEFFfoo 中文 😀" {
+		t.Fatal()
+	}
+	// odd length UTF-16
+	r, _, err = NewFromReaderWithEncoding(bytes.NewReader([]byte{'a', 0, 'b'}), EncodingUTF16LE)
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) || truncated.Offset != 2 || !errors.Is(err, io.ErrUnexpectedEOF) ||
+		string(r.Bytes()) != "a\uFFFD" {
+		t.Fatal()
+	}
+	long := append(bytes.Repeat([]byte{'a', 0}, transcodeChunkSize), 'b')
+	r, _, err = NewFromReaderWithEncoding(bytes.NewReader(long), EncodingUTF16LE)
+	if !errors.As(err, &truncated) || truncated.Offset != int64(len(long)-1) || r.Len() != transcodeChunkSize+3 {
+		t.Fatal()
+	}
+	if _, err := NewFromBytes([]byte("中")).WriteToWithEncoding(io.Discard, EncodingWindows1252); err != ErrUnrepresentable {
+		t.Fatal()
+	}
+	if _, err := NewFromBytes([]byte{'a', 0xFF}).WriteToWithEncoding(io.Discard, EncodingUTF16LE); err != ErrUnrepresentable {
+		t.Fatal()
+	}
+	buf := new(bytes.Buffer)
+	if _, err := NewFromBytes([]byte("aé")).WriteToWithEncoding(buf, EncodingLatin1); err != nil || buf.String() != "a\xe9" {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,282 @@
+package rope
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// chunks calls fn with the content of the rope in pieces cut at rune boundaries,
+// fn must not retain the pieces
+func (r *Rope) chunks(fn func([]byte) bool) {
+	var carry []byte
+	if !r.Iter(0, func(bs []byte) bool {
+		if len(carry) > 0 {
+			// complete the rune cut by the previous leaf boundary
+			k := 0
+			for !utf8.FullRune(carry) && k < len(bs) {
+				carry = append(carry, bs[k])
+				k++
+			}
+			if !utf8.FullRune(carry) {
+				return true
+			}
+			if !fn(carry) {
+				return false
+			}
+			carry = carry[:0]
+			bs = bs[k:]
+		}
+		cut := len(bs)
+		for i := len(bs) - 1; i >= 0 && i >= len(bs)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(bs[i]) {
+				if !utf8.FullRune(bs[i:]) {
+					cut = i
+				}
+				break
+			}
+		}
+		carry = append(carry, bs[cut:]...)
+		return cut == 0 || fn(bs[:cut])
+	}) {
+		return
+	}
+	if len(carry) > 0 {
+		fn(carry)
+	}
+}
+
+// chunks calls fn with the content of the rope encoded in UTF-8, one piece per leaf,
+// fn must not retain the pieces
+func (r *RuneRope) chunks(fn func([]byte) bool) {
+	var buf []byte
+	r.Iter(0, func(rs []rune) bool {
+		buf = buf[:0]
+		for _, c := range rs {
+			buf = utf8.AppendRune(buf, c)
+		}
+		return fn(buf)
+	})
+}
+
+// String returns the content of the rope
+func (r *Rope) String() string {
+	return chunksString(r.chunks, r.Len())
+}
+
+// String returns the content of the rope
+func (r *RuneRope) String() string {
+	return chunksString(r.chunks, r.Len())
+}
+
+func chunksString(chunks func(func([]byte) bool), size int) string {
+	var b strings.Builder
+	b.Grow(size)
+	chunks(func(bs []byte) bool {
+		b.Write(bs)
+		return true
+	})
+	return b.String()
+}
+
+// Format implements fmt.Formatter, %s, %v and %q write from the leaves and support
+// width and precision like strings, other verbs format the String
+func (r *Rope) Format(f fmt.State, verb rune) {
+	format(f, verb, r.chunks, r.String)
+}
+
+// Format implements fmt.Formatter, %s, %v and %q write from the leaves and support
+// width and precision like strings, other verbs format the String
+func (r *RuneRope) Format(f fmt.State, verb rune) {
+	format(f, verb, r.chunks, r.String)
+}
+
+func format(f fmt.State, verb rune, chunks func(func([]byte) bool), str func() string) {
+	var quote func(buf []byte, s string) []byte
+	switch {
+	case verb == 's' || verb == 'v' && !f.Flag('#'):
+	case verb == 'q' && f.Flag('+') && !f.Flag('#'):
+		quote = strconv.AppendQuoteToASCII
+	case verb == 'q' && !f.Flag('#'):
+		quote = strconv.AppendQuote
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), str())
+		return
+	}
+
+	// limit to the precision in runes
+	if prec, ok := f.Precision(); ok {
+		all := chunks
+		chunks = func(fn func([]byte) bool) {
+			n := prec
+			all(func(bs []byte) bool {
+				if c := utf8.RuneCount(bs); c < n {
+					n -= c
+					return fn(bs)
+				}
+				i := 0
+				for ; n > 0; n-- {
+					_, size := utf8.DecodeRune(bs[i:])
+					i += size
+				}
+				if i > 0 {
+					fn(bs[:i])
+				}
+				return false
+			})
+		}
+	}
+
+	var buf []byte
+	each := func(fn func([]byte)) {
+		if quote != nil {
+			fn([]byte{'"'})
+		}
+		chunks(func(bs []byte) bool {
+			if quote != nil {
+				buf = quote(buf[:0], string(bs))
+				bs = buf[1 : len(buf)-1]
+			}
+			fn(bs)
+			return true
+		})
+		if quote != nil {
+			fn([]byte{'"'})
+		}
+	}
+
+	pad := 0
+	if width, ok := f.Width(); ok {
+		n := 0
+		each(func(bs []byte) {
+			n += utf8.RuneCount(bs)
+		})
+		pad = max(width-n, 0)
+	}
+	if !f.Flag('-') {
+		io.WriteString(f, strings.Repeat(" ", pad))
+	}
+	each(func(bs []byte) {
+		f.Write(bs)
+	})
+	if f.Flag('-') {
+		io.WriteString(f, strings.Repeat(" ", pad))
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s escaped for a JSON string byte for byte like json.Marshal does for a
+// string: <, >, &, U+2028 and U+2029 are escaped, each invalid byte becomes a raw U+FFFD
+func appendJSONString(buf, s []byte) []byte {
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\b':
+				buf = append(buf, '\\', 'b')
+			case c == '\f':
+				buf = append(buf, '\\', 'f')
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20 || c == '<' || c == '>' || c == '&':
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		c, size := utf8.DecodeRune(s[i:])
+		switch {
+		case c == utf8.RuneError && size == 1:
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+		case c == '\u2028' || c == '\u2029':
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xf])
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
+}
+
+func marshalJSON(chunks func(func([]byte) bool), size int) []byte {
+	buf := make([]byte, 0, size+2)
+	buf = append(buf, '"')
+	chunks(func(bs []byte) bool {
+		buf = appendJSONString(buf, bs)
+		return true
+	})
+	return append(buf, '"')
+}
+
+// MarshalJSON encodes the rope as a JSON string, invalid UTF-8 is replaced by U+FFFD
+func (r *Rope) MarshalJSON() ([]byte, error) {
+	return marshalJSON(r.chunks, r.Len()), nil
+}
+
+// MarshalJSON encodes the rope as a JSON string
+func (r *RuneRope) MarshalJSON() ([]byte, error) {
+	return marshalJSON(r.chunks, r.Len()), nil
+}
+
+// UnmarshalJSON replaces the content of r with the JSON string in data, keeping its configuration.
+//
+// Unlike every other method it modifies r in place: r must be a rope the caller owns, such as
+// a zero Rope being decoded, and never a node reachable from another rope or a Builder.
+func (r *Rope) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	n := newFromBytes([]byte(s), r.config())
+	r.height, r.weight, r.left, r.right, r.content = n.height, n.weight, n.left, n.right, n.content
+	r.owner = nil
+	r.summaries = atomic.Value{} // the cached summaries are those of the old content
+	return nil
+}
+
+// UnmarshalJSON replaces the content of r with the JSON string in data, keeping its configuration.
+//
+// Unlike every other method it modifies r in place: r must be a rope the caller owns, such as
+// a zero RuneRope being decoded, and never a node reachable from another rope.
+func (r *RuneRope) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	n := newFromRunes([]rune(s), r.config())
+	r.height, r.weight, r.left, r.right, r.content = n.height, n.weight, n.left, n.right, n.content
+	return nil
+}
+
+// MarshalText returns the content of the rope
+func (r *Rope) MarshalText() ([]byte, error) {
+	return r.Bytes(), nil
+}
+
+// MarshalText returns the content of the rope encoded in UTF-8
+func (r *RuneRope) MarshalText() ([]byte, error) {
+	buf := make([]byte, 0, r.Len())
+	r.chunks(func(bs []byte) bool {
+		buf = append(buf, bs...)
+		return true
+	})
+	return buf, nil
+}
@@ -0,0 +1,133 @@
+package rope
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	s := strings.Repeat("foo·bar·baz", 20)
+	if NewFromBytes([]byte(s)).String() != s || NewFromRunes([]rune(s)).String() != s {
+		t.Fatal()
+	}
+	var r *Rope
+	if r.String() != "" {
+		t.Fatal()
+	}
+}
+
+func TestChunks(t *testing.T) {
+	// runes cut by leaf boundaries, and invalid bytes
+	s := strings.Repeat("a·€😀", 10) + "\xe2\x82\xff\xf0\x9f"
+	var quoted []byte
+	NewFromBytes([]byte(s)).chunks(func(chunk []byte) bool {
+		q := strconv.Quote(string(chunk))
+		quoted = append(quoted, q[1:len(q)-1]...)
+		return true
+	})
+	if q := strconv.Quote(s); string(quoted) != q[1:len(q)-1] {
+		t.Fatal(string(quoted))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s := strings.Repeat("foo·\"bar\"\tbaz\xff", 5) + "é"
+	cases := []string{"%s", "%v", "%q", "%+q", "%#q", "%x", "%X", "% x", "%10.3s", "%-10.3s",
+		"%.5q", "%200s", "%-200q", "%.0s", "%8.100s", "%#v"}
+	for _, runes := range []bool{false, true} {
+		for _, format := range cases {
+			var arg any = NewFromBytes([]byte(s))
+			expected := fmt.Sprintf(format, s)
+			if runes {
+				arg = NewFromRunes([]rune(s))
+				expected = fmt.Sprintf(format, string([]rune(s)))
+			}
+			if format == "%#v" {
+				expected = fmt.Sprintf("%#v", fmt.Sprint(arg))
+			}
+			if got := fmt.Sprintf(format, arg); got != expected {
+				t.Fatalf("%s %v: %s, expected %s", format, runes, got, expected)
+			}
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	s := strings.Repeat("foo<bar>&\"baz\"\n\x01\b\f  ·\\", 5) + "\xff"
+	expected, _ := json.Marshal(s)
+	r := NewFromBytes([]byte(s))
+	got, err := json.Marshal(r)
+	if err != nil || !bytes.Equal(got, expected) {
+		t.Fatalf("%s %v", got, err)
+	}
+	rr := NewFromRunes([]rune(s))
+	expected, _ = json.Marshal(string([]rune(s)))
+	got, err = json.Marshal(rr)
+	if err != nil || !bytes.Equal(got, expected) {
+		t.Fatalf("%s %v", got, err)
+	}
+
+	var v struct {
+		R  Rope
+		RR *RuneRope
+		N  *Rope
+	}
+	data := []byte(`{"R":"foobarbaz","RR":"foo·bar","N":null}`)
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.R.String() != "foobarbaz" || v.RR.String() != "foo·bar" || v.N != nil {
+		t.Fatal()
+	}
+	if v.R.Validate() != nil || v.RR.Validate() != nil {
+		t.Fatal()
+	}
+	if err := json.Unmarshal([]byte(`{"R":1}`), &v); err == nil {
+		t.Fatal()
+	}
+	out, err := json.Marshal(&v)
+	if err != nil || string(out) != `{"R":"foobarbaz","RR":"foo·bar","N":null}` {
+		t.Fatalf("%s %v", out, err)
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	for _, s := range []string{"a\xffb\xed\xa0\x80c\xf0\x9f", "<>&\u2028\u2029", "\x00\x1f\"\\\t·😀"} {
+		expected, _ := json.Marshal(s)
+		// leaves of 3 bytes split the runes
+		got, err := NewFromBytesWithConfig([]byte(s), &Config[byte]{MaxLengthPerNode: 3}).MarshalJSON()
+		if err != nil || !bytes.Equal(got, expected) {
+			t.Fatalf("%q %q", got, expected)
+		}
+	}
+}
+
+func TestUnmarshalJSONResetsCache(t *testing.T) {
+	r := NewFromBytes([]byte("a\nb"))
+	if r.LineCount() != 2 {
+		t.Fatal()
+	}
+	if err := r.UnmarshalJSON([]byte(`"a\nb\nc\nd"`)); err != nil || r.LineCount() != 4 || r.Validate() != nil {
+		t.Fatal()
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	s := strings.Repeat("foo·bar", 10)
+	text, err := NewFromBytes([]byte(s)).MarshalText()
+	if err != nil || string(text) != s {
+		t.Fatal()
+	}
+	text, err = NewFromRunes([]rune(s)).MarshalText()
+	if err != nil || string(text) != s {
+		t.Fatal()
+	}
+	m := map[string]*Rope{"a": NewFromBytes([]byte("b"))}
+	if fmt.Sprint(m) != "map[a:b]" {
+		t.Fatal(fmt.Sprint(m))
+	}
+}
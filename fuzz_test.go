@@ -0,0 +1,351 @@
+package rope
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets decode ops with fuzzOps and apply them to a rope and to a plain slice model,
+// validating the tree after each step. Every version is kept to check persistence at the end.
+
+var fuzzSeeds = [][2][]byte{
+	{[]byte("foobarbaz"), []byte{0, 3, 4, 1, 2, 5, 2, 7, 3, 3, 4, 250, 4, 1, 0, 5, 9, 9}},
+	{[]byte("foobarbaz"), []byte{0, 3, 4, 1, 2, 5, 2, 200, 3, 3, 4, 250, 20}},
+	{[]byte(""), []byte{0, 0, 40, 0, 20, 30, 2, 15, 0, 1, 10, 200, 5, 3, 3, 4, 0, 0}},
+	{[]byte(""), []byte{1, 255, 1, 0, 0, 0, 2, 1, 3, 0, 128}},
+	{bytes.Repeat([]byte("0123456789"), 20), []byte{2, 100, 0, 1, 50, 100, 3, 10, 180, 5, 1, 60, 4, 90, 0}},
+	{[]byte("foo\nbär\n\nbaz qux\n中文\n"), []byte{2, 3, 0, 0, 1, 3, 1, 2, 130, 3, 0, 127, 6, 0, 9, 2, 5, 0}},
+}
+
+// fuzzOps calls fn for each op of three bytes in ops: the operation, an offset in [-1, length()+1]
+// and a length in [-128, 127], ranging past both ends to exercise clamping
+func fuzzOps(ops []byte, length func() int, fn func(op, n, l int)) {
+	for ; len(ops) >= 3; ops = ops[3:] {
+		fn(int(ops[0])%8, int(ops[1])%(length()+3)-1, int(int8(ops[2])))
+	}
+}
+
+// clampModel clamps the range of l elements at n to s like the ropes do
+func clampModel[T any](s []T, n, l int) (int, int) {
+	end := n + max(l, 0)
+	n = max(0, min(n, len(s)))
+	end = max(n, min(end, len(s)))
+	return n, end
+}
+
+// inRange reports whether the range of l elements at n is inside s
+func inRange[T any](s []T, n, l int) bool {
+	return n >= 0 && l >= 0 && n+l <= len(s)
+}
+
+// insertModel returns s with ins inserted at n clamped
+func insertModel[T any](s []T, n int, ins []T) []T {
+	n, _ = clampModel(s, n, 0)
+	return append(s[:n:n], append(ins, s[n:]...)...)
+}
+
+// deleteModel returns s without the range of l elements at n clamped
+func deleteModel[T any](s []T, n, l int) []T {
+	n, end := clampModel(s, n, l)
+	return append(s[:n:n], s[end:]...)
+}
+
+func FuzzRope(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, text []byte, ops []byte) {
+		model := append([]byte(nil), text...)
+		r := NewFromBytes(text)
+		versions := []*Rope{r}
+		models := [][]byte{model}
+		fuzzOps(ops, func() int { return len(model) }, func(op, n, l int) {
+			switch op {
+			case 0:
+				ins := bytes.Repeat([]byte{byte('a' + l&0xF)}, l&0xF)
+				if _, err := r.TryInsert(n, ins); (err == nil) != inRange(model, n, 0) {
+					t.Fatal()
+				}
+				r = r.Insert(n, ins)
+				model = insertModel(model, n, ins)
+			case 1:
+				if _, err := r.TryDelete(n, l); (err == nil) != inRange(model, n, l) {
+					t.Fatal()
+				}
+				r = r.Delete(n, l)
+				model = deleteModel(model, n, l)
+			case 2:
+				r1, r2 := r.Split(n)
+				i, _ := clampModel(model, n, 0)
+				if r1.Validate() != nil || !bytes.Equal(r1.Bytes(), model[:i]) ||
+					r2.Validate() != nil || !bytes.Equal(r2.Bytes(), model[i:]) {
+					t.Fatal()
+				}
+				r = r2.Concat(r1)
+				model = append(model[i:len(model):len(model)], model[:i]...)
+			case 3:
+				i, end := clampModel(model, n, l)
+				if !bytes.Equal(r.Sub(n, l), model[i:end]) {
+					t.Fatal()
+				}
+				sub, err := r.TrySub(n, l)
+				if (err == nil) != inRange(model, n, l) || err == nil && !bytes.Equal(sub, model[n:n+l]) {
+					t.Fatal()
+				}
+			case 4:
+				b, err := r.TryIndex(n)
+				if (err == nil) != inRange(model, n, 1) || err == nil && b != model[n] {
+					t.Fatal()
+				}
+			case 5:
+				i, _ := clampModel(model, n, 0)
+				var got []byte
+				r.Iter(n, func(bs []byte) bool {
+					got = append(got, bs...)
+					return true
+				})
+				if !bytes.Equal(got, model[i:]) {
+					t.Fatal()
+				}
+			case 6:
+				ins := bytes.Repeat([]byte{byte('A' + n%26)}, l&0x3F)
+				r = r.Concat(NewFromBytes(ins))
+				model = append(model[:len(model):len(model)], ins...)
+			case 7:
+				i, _ := clampModel(model, n, 0)
+				var got []byte
+				r.IterBackward(n, func(bs []byte) bool {
+					got = append(got, bs...)
+					return true
+				})
+				if !bytes.Equal(got, reversedBytes(model[:i])) {
+					t.Fatal()
+				}
+			}
+			if err := r.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if r.Len() != len(model) || !bytes.Equal(r.Bytes(), model) {
+				t.Fatal()
+			}
+			versions = append(versions, r)
+			models = append(models, model)
+		})
+		for i, v := range versions {
+			if !bytes.Equal(v.Bytes(), models[i]) {
+				t.Fatal()
+			}
+		}
+	})
+}
+
+func FuzzRuneRope(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, text []byte, ops []byte) {
+		cfg := &Config[rune]{MaxLengthPerNode: 4}
+		model := []rune(string(text))
+		r := NewFromRunesWithConfig(model, cfg)
+		model = append([]rune(nil), model...)
+		versions := []*RuneRope{r}
+		models := [][]rune{model}
+		fuzzOps(ops, func() int { return len(model) }, func(op, n, l int) {
+			switch op {
+			case 0:
+				ins := []rune(strings.Repeat("é", l&0xF))
+				if _, err := r.TryInsert(n, ins); (err == nil) != inRange(model, n, 0) {
+					t.Fatal()
+				}
+				r = r.Insert(n, ins)
+				model = insertModel(model, n, ins)
+			case 1:
+				if _, err := r.TryDelete(n, l); (err == nil) != inRange(model, n, l) {
+					t.Fatal()
+				}
+				r = r.Delete(n, l)
+				model = deleteModel(model, n, l)
+			case 2:
+				r1, r2 := r.Split(n)
+				i, _ := clampModel(model, n, 0)
+				if r1.Validate() != nil || string(r1.Runes()) != string(model[:i]) ||
+					r2.Validate() != nil || string(r2.Runes()) != string(model[i:]) {
+					t.Fatal()
+				}
+				r = r2.Concat(r1)
+				model = append(model[i:len(model):len(model)], model[:i]...)
+			case 3:
+				i, end := clampModel(model, n, l)
+				if string(r.Sub(n, l)) != string(model[i:end]) {
+					t.Fatal()
+				}
+				sub, err := r.TrySub(n, l)
+				if (err == nil) != inRange(model, n, l) || err == nil && string(sub) != string(model[n:n+l]) {
+					t.Fatal()
+				}
+			case 4:
+				c, err := r.TryIndex(n)
+				if (err == nil) != inRange(model, n, 1) || err == nil && c != model[n] {
+					t.Fatal()
+				}
+			case 5:
+				i, _ := clampModel(model, n, 0)
+				var got []rune
+				r.Iter(n, func(rs []rune) bool {
+					got = append(got, rs...)
+					return true
+				})
+				if string(got) != string(model[i:]) {
+					t.Fatal()
+				}
+			case 6:
+				ins := []rune(strings.Repeat(string(rune('A'+n%26)), l&0x3F))
+				r = r.Concat(NewFromRunesWithConfig(ins, cfg))
+				model = append(model[:len(model):len(model)], ins...)
+			case 7:
+				i, _ := clampModel(model, n, 0)
+				var got []rune
+				r.IterBackward(n, func(rs []rune) bool {
+					got = append(got, rs...)
+					return true
+				})
+				if string(got) != string(reversedrunes(model[:i])) {
+					t.Fatal()
+				}
+			}
+			if err := r.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if r.Len() != len(model) || string(r.Runes()) != string(model) {
+				t.Fatal()
+			}
+			versions = append(versions, r)
+			models = append(models, model)
+		})
+		for i, v := range versions {
+			if string(v.Runes()) != string(models[i]) {
+				t.Fatal()
+			}
+		}
+	})
+}
+
+// equalRows compares rows of a RopeRope with a model of lines
+func equalRows(rows []Rope, model []string) bool {
+	if len(rows) != len(model) {
+		return false
+	}
+	for i := range rows {
+		if string(rows[i].Bytes()) != model[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func FuzzRopeRope(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, text []byte, ops []byte) {
+		cfg := &Config[Rope]{MaxLengthPerNode: 4}
+		rows := func(lines []string) []Rope {
+			ret := make([]Rope, len(lines))
+			for i, line := range lines {
+				ret[i] = *NewFromBytes([]byte(line))
+			}
+			return ret
+		}
+		var model []string
+		if len(text) > 0 {
+			model = strings.SplitAfter(string(text), "\n")
+		}
+		r := NewFromRopeWithConfig(rows(model), cfg)
+		versions := []*RopeRope{r}
+		models := [][]string{model}
+		fuzzOps(ops, func() int { return len(model) }, func(op, n, l int) {
+			switch op {
+			case 0:
+				var ins []string
+				for i := 0; i < l&0xF; i++ {
+					ins = append(ins, strings.Repeat("xé", i)+"\n")
+				}
+				if _, err := r.TryInsert(n, rows(ins)); (err == nil) != inRange(model, n, 0) {
+					t.Fatal()
+				}
+				r = r.Insert(n, rows(ins))
+				model = insertModel(model, n, ins)
+			case 1:
+				if _, err := r.TryDelete(n, l); (err == nil) != inRange(model, n, l) {
+					t.Fatal()
+				}
+				r = r.Delete(n, l)
+				model = deleteModel(model, n, l)
+			case 2:
+				r1, r2 := r.Split(n)
+				i, _ := clampModel(model, n, 0)
+				if r1.Validate() != nil || !equalRows(r1.Sub(0, r1.Len()), model[:i]) ||
+					r2.Validate() != nil || !equalRows(r2.Sub(0, r2.Len()), model[i:]) {
+					t.Fatal()
+				}
+				r = r2.Concat(r1)
+				model = append(model[i:len(model):len(model)], model[:i]...)
+			case 3:
+				i, end := clampModel(model, n, l)
+				if !equalRows(r.Sub(n, l), model[i:end]) {
+					t.Fatal()
+				}
+				sub, err := r.TrySub(n, l)
+				if (err == nil) != inRange(model, n, l) || err == nil && !equalRows(sub, model[n:n+l]) {
+					t.Fatal()
+				}
+			case 4:
+				row, err := r.TryIndex(n)
+				if (err == nil) != inRange(model, n, 1) || err == nil && string(row.Bytes()) != model[n] {
+					t.Fatal()
+				}
+			case 5:
+				i, _ := clampModel(model, n, 0)
+				var got []Rope
+				r.Iter(n, func(rs []Rope) bool {
+					got = append(got, rs...)
+					return true
+				})
+				if !equalRows(got, model[i:]) {
+					t.Fatal()
+				}
+			case 6:
+				ins := []string{"foo\n", "\n", "bär"}[:l&3%3]
+				r = r.Concat(NewFromRopeWithConfig(rows(ins), cfg))
+				model = append(model[:len(model):len(model)], ins...)
+			case 7:
+				i, _ := clampModel(model, n, 0)
+				var got []Rope
+				r.IterBackward(n, func(rs []Rope) bool {
+					got = append(got, rs...)
+					return true
+				})
+				reversed := slices.Clone(model[:i])
+				slices.Reverse(reversed)
+				if !equalRows(got, reversed) {
+					t.Fatal()
+				}
+			}
+			if err := r.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if r.Len() != len(model) || !equalRows(r.Sub(0, r.Len()), model) {
+				t.Fatal()
+			}
+			versions = append(versions, r)
+			models = append(models, model)
+		})
+		for i, v := range versions {
+			if !equalRows(v.Sub(0, v.Len()), models[i]) {
+				t.Fatal()
+			}
+		}
+	})
+}
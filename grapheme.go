@@ -0,0 +1,375 @@
+package rope
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Grapheme cluster segmentation per UAX #29 (extended grapheme clusters)
+
+type gcbProp int
+
+const (
+	gcbOther gcbProp = iota
+	gcbCR
+	gcbLF
+	gcbControl
+	gcbExtend
+	gcbZWJ
+	gcbRI
+	gcbPrepend
+	gcbSpacingMark
+	gcbL
+	gcbV
+	gcbT
+	gcbLV
+	gcbLVT
+	gcbExtPict
+	gcbConsonant // Other with InCB=Consonant
+)
+
+type gcbRange struct {
+	lo, hi rune
+	prop   gcbProp
+}
+
+func graphemeProperty(c rune) gcbProp {
+	switch {
+	case c >= 0x20 && c < 0x7F:
+		return gcbOther
+	case c >= 0xAC00 && c <= 0xD7A3:
+		if (c-0xAC00)%28 == 0 {
+			return gcbLV
+		}
+		return gcbLVT
+	}
+	i := sort.Search(len(graphemeBreakRanges), func(i int) bool {
+		return graphemeBreakRanges[i].hi >= c
+	})
+	if i < len(graphemeBreakRanges) && graphemeBreakRanges[i].lo <= c {
+		return graphemeBreakRanges[i].prop
+	}
+	return gcbOther
+}
+
+// states of the GB9c rule
+const (
+	incbNone   = iota
+	incbStart  // InCB=Consonant [InCB=Extend InCB=Linker]*
+	incbLinked // same with at least one InCB=Linker
+)
+
+// graphemeState is the segmentation state after a sequence of runes
+type graphemeState struct {
+	started  bool
+	prev     gcbProp
+	ri       int  // parity of the regional indicators ending at prev
+	pict     bool // Extended_Pictographic Extend* ends at prev
+	emojiZWJ bool // prev is a ZWJ preceded by Extended_Pictographic Extend*
+	incb     int
+}
+
+// breaks reports whether there is a boundary between the runes fed so far and a rune of property b
+func (s *graphemeState) breaks(b gcbProp) bool {
+	a := s.prev
+	switch {
+	case !s.started:
+		return true
+	case a == gcbCR && b == gcbLF: // GB3
+		return false
+	case a == gcbCR || a == gcbLF || a == gcbControl: // GB4
+		return true
+	case b == gcbCR || b == gcbLF || b == gcbControl: // GB5
+		return true
+	case a == gcbL && (b == gcbL || b == gcbV || b == gcbLV || b == gcbLVT): // GB6
+		return false
+	case (a == gcbLV || a == gcbV) && (b == gcbV || b == gcbT): // GB7
+		return false
+	case (a == gcbLVT || a == gcbT) && b == gcbT: // GB8
+		return false
+	case b == gcbExtend || b == gcbZWJ: // GB9
+		return false
+	case b == gcbSpacingMark: // GB9a
+		return false
+	case a == gcbPrepend: // GB9b
+		return false
+	case b == gcbConsonant && s.incb == incbLinked: // GB9c
+		return false
+	case a == gcbZWJ && b == gcbExtPict: // GB11
+		return !s.emojiZWJ
+	case a == gcbRI && b == gcbRI: // GB12, GB13
+		return s.ri%2 == 0
+	}
+	return true // GB999
+}
+
+// advance feeds the rune c of property p
+func (s *graphemeState) advance(c rune, p gcbProp) {
+	s.started = true
+	s.prev = p
+	s.emojiZWJ = p == gcbZWJ && s.pict
+	if p == gcbExtPict {
+		s.pict = true
+	} else if p != gcbExtend {
+		s.pict = false
+	}
+	if p == gcbRI {
+		s.ri++
+	} else {
+		s.ri = 0
+	}
+	switch {
+	case p == gcbConsonant:
+		s.incb = incbStart
+	case s.incb != incbNone && unicode.Is(incbLinker, c):
+		s.incb = incbLinked
+	case s.incb != incbNone && unicode.Is(incbExtend, c):
+	default:
+		s.incb = incbNone
+	}
+}
+
+// next feeds the rune c and reports whether a cluster starts at it
+func (s *graphemeState) next(c rune) bool {
+	p := graphemeProperty(c)
+	ret := s.breaks(p)
+	s.advance(c, p)
+	return ret
+}
+
+// riSummary maps each riMeasure state at the start of some bytes to the state at their end.
+// A state is 4 * the parity of the regional indicators ending there + the number of bytes
+// of the pending regional indicator, whose encoding is F0 9F 87 A6..BF.
+type riSummary [8]uint8
+
+type riMeasure struct{}
+
+func (riMeasure) Summarize(bs []byte) (s riSummary) {
+	for i := range s {
+		st := uint8(i)
+		for _, b := range bs {
+			st = riStep(st, b)
+		}
+		s[i] = st
+	}
+	return
+}
+
+func (riMeasure) Combine(a, b riSummary) (s riSummary) {
+	for i := range s {
+		s[i] = b[a[i]]
+	}
+	return
+}
+
+func riStep(st uint8, b byte) uint8 {
+	parity, k := st&4, st&3
+	switch {
+	case k == 1 && b == 0x9F, k == 2 && b == 0x87:
+		return parity | (k + 1)
+	case k == 3 && b >= 0xA6 && b <= 0xBF:
+		return parity ^ 4
+	case b == 0xF0 && k == 0:
+		return parity | 1
+	case b == 0xF0: // any other byte ends the run
+		return 1
+	}
+	return 0
+}
+
+// riParity returns the parity of the regional indicators ending at offset
+func (r *Rope) riParity(offset int) int {
+	st := PrefixSummary(r, riMeasure{}, offset)[0]
+	if st&3 != 0 { // offset follows the bytes of an incomplete rune
+		return 0
+	}
+	return int(st >> 2)
+}
+
+// iterRunes iterates runes from offset, decoding runes that span leaves.
+// Invalid bytes are reported as utf8.RuneError with their raw bytes,
+// raw is only valid during the call.
+func (r *Rope) iterRunes(offset int, fn func(c rune, raw []byte) bool) {
+	var buf [utf8.UTFMax]byte
+	n := 0
+	stopped := false
+	r.Iter(offset, func(bs []byte) bool {
+		for {
+			if n > 0 { // complete the pending rune
+				for n < len(buf) && len(bs) > 0 && !utf8.FullRune(buf[:n]) {
+					buf[n] = bs[0]
+					n++
+					bs = bs[1:]
+				}
+				if !utf8.FullRune(buf[:n]) {
+					return true
+				}
+				c, l := utf8.DecodeRune(buf[:n])
+				if !fn(c, buf[:l]) {
+					stopped = true
+					return false
+				}
+				n = copy(buf[:], buf[l:n])
+				continue
+			}
+			if len(bs) == 0 {
+				return true
+			}
+			if !utf8.FullRune(bs) {
+				n = copy(buf[:], bs)
+				return true
+			}
+			c, l := utf8.DecodeRune(bs)
+			if !fn(c, bs[:l]) {
+				stopped = true
+				return false
+			}
+			bs = bs[l:]
+		}
+	})
+	for !stopped && n > 0 {
+		c, l := utf8.DecodeRune(buf[:n])
+		if !fn(c, buf[:l]) {
+			return
+		}
+		n = copy(buf[:], buf[l:n])
+	}
+}
+
+// runeAt decodes the rune starting at offset
+func (r *Rope) runeAt(offset int) (ret rune, size int) {
+	r.iterRunes(offset, func(c rune, raw []byte) bool {
+		ret = c
+		size = len(raw)
+		return false
+	})
+	return
+}
+
+// runeBefore decodes the rune ending at offset
+func (r *Rope) runeBefore(offset int) (rune, int) {
+	start := offset - utf8.UTFMax
+	if start < 0 {
+		start = 0
+	}
+	return utf8.DecodeLastRune(r.Sub(start, offset-start))
+}
+
+// graphemeStateAt returns the segmentation state after the runes before offset.
+// The parity of regional indicators comes from cached summaries, other rules look back
+// over Extend runes only.
+func (r *Rope) graphemeStateAt(offset int) (s graphemeState) {
+	if offset <= 0 {
+		return
+	}
+	c, size := r.runeBefore(offset)
+	p := graphemeProperty(c)
+	s.started = true
+	s.prev = p
+	if p == gcbRI {
+		s.ri = r.riParity(offset)
+	}
+	// Extended_Pictographic Extend* before offset, and before a ZWJ ending at offset
+	o := offset
+	if p == gcbZWJ {
+		o -= size
+	}
+	for o > 0 {
+		c, size := r.runeBefore(o)
+		q := graphemeProperty(c)
+		if q != gcbExtend {
+			if q == gcbExtPict {
+				s.pict = p != gcbZWJ
+				s.emojiZWJ = p == gcbZWJ
+			}
+			break
+		}
+		o -= size
+	}
+	// InCB=Consonant [InCB=Extend InCB=Linker]* before offset
+	linked := false
+	for o = offset; o > 0; {
+		c, size := r.runeBefore(o)
+		if graphemeProperty(c) == gcbConsonant {
+			s.incb = incbStart
+			if linked {
+				s.incb = incbLinked
+			}
+			break
+		}
+		if unicode.Is(incbLinker, c) {
+			linked = true
+		} else if !unicode.Is(incbExtend, c) {
+			break
+		}
+		o -= size
+	}
+	return
+}
+
+// isGraphemeBoundary reports whether offset, which must be at a rune start, is a grapheme cluster boundary
+func (r *Rope) isGraphemeBoundary(offset int) bool {
+	if offset <= 0 || offset >= r.Len() {
+		return true
+	}
+	c, _ := r.runeAt(offset)
+	s := r.graphemeStateAt(offset)
+	return s.breaks(graphemeProperty(c))
+}
+
+// NextGraphemeBoundary returns the first grapheme cluster boundary after offset.
+// offset must be at the start of a rune, the same applies to PrevGraphemeBoundary.
+func (r *Rope) NextGraphemeBoundary(offset int) int {
+	l := r.Len()
+	if offset >= l {
+		return l
+	}
+	s := r.graphemeStateAt(offset)
+	ret := l
+	first := true
+	r.iterRunes(offset, func(c rune, raw []byte) bool {
+		if !s.next(c) || first {
+			first = false
+			offset += len(raw)
+			return true
+		}
+		ret = offset
+		return false
+	})
+	return ret
+}
+
+// PrevGraphemeBoundary returns the last grapheme cluster boundary before offset
+func (r *Rope) PrevGraphemeBoundary(offset int) int {
+	for offset > 0 {
+		_, size := r.runeBefore(offset)
+		offset -= size
+		if r.isGraphemeBoundary(offset) {
+			return offset
+		}
+	}
+	return 0
+}
+
+// IterGrapheme iterates grapheme clusters starting at offset, which is treated as a boundary.
+// The cluster slice is reused between calls.
+func (r *Rope) IterGrapheme(offset int, fn func(cluster []byte) bool) {
+	var cluster []byte
+	var state graphemeState
+	stopped := false
+	r.iterRunes(offset, func(c rune, raw []byte) bool {
+		if state.next(c) && len(cluster) > 0 {
+			if !fn(cluster) {
+				stopped = true
+				return false
+			}
+			cluster = cluster[:0]
+		}
+		cluster = append(cluster, raw...)
+		return true
+	})
+	if !stopped && len(cluster) > 0 {
+		fn(cluster)
+	}
+}
@@ -0,0 +1,157 @@
+package rope
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGrapheme(t *testing.T) {
+	clusters := []string{
+		"a", "é", "\r\n", "👨‍👩‍👧‍👦", "🇺🇸", "🇫🇷", "x",
+		"한", "각", "👍🏽", "कि", "\n", "我", "❤️",
+		"؀a", "b",
+	}
+	s := strings.Join(clusters, "")
+	r := NewFromBytes([]byte(s))
+
+	var res []string
+	r.IterGrapheme(0, func(bs []byte) bool {
+		res = append(res, string(bs))
+		return true
+	})
+	if len(res) != len(clusters) {
+		t.Fatalf("%q", res)
+	}
+	for i, c := range clusters {
+		if res[i] != c {
+			t.Fatalf("%q %q", res[i], c)
+		}
+	}
+
+	n := 0
+	r.IterGrapheme(0, func(bs []byte) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatal()
+	}
+
+	var boundaries []int
+	off := 0
+	for _, c := range clusters {
+		boundaries = append(boundaries, off)
+		off += len(c)
+	}
+	boundaries = append(boundaries, off)
+	i := 0
+	for o := range s {
+		if o == boundaries[i+1] {
+			i++
+		}
+		if r.NextGraphemeBoundary(o) != boundaries[i+1] {
+			t.Fatalf("next %d", o)
+		}
+		if o > boundaries[i] && r.PrevGraphemeBoundary(o) != boundaries[i] {
+			t.Fatalf("prev %d", o)
+		}
+		if o == boundaries[i] && o > 0 && r.PrevGraphemeBoundary(o) != boundaries[i-1] {
+			t.Fatalf("prev %d", o)
+		}
+	}
+	if r.NextGraphemeBoundary(len(s)) != len(s) || r.PrevGraphemeBoundary(0) != 0 {
+		t.Fatal()
+	}
+}
+
+// testGraphemeBreakFile checks segmentation against a file in the format of GraphemeBreakTest.txt
+func testGraphemeBreakFile(t *testing.T, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var bs []byte
+		var boundaries []int
+		for _, field := range fields {
+			switch field {
+			case "÷":
+				boundaries = append(boundaries, len(bs))
+			case "×":
+			default:
+				c, err := strconv.ParseUint(field, 16, 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				bs = append(bs, string(rune(c))...)
+			}
+		}
+		r := NewFromBytes(bs)
+		var got []int
+		off := 0
+		r.IterGrapheme(0, func(cluster []byte) bool {
+			got = append(got, off)
+			off += len(cluster)
+			return true
+		})
+		got = append(got, off)
+		if len(got) != len(boundaries) {
+			t.Fatalf("%s: %v", line, got)
+		}
+		for i := range got {
+			if got[i] != boundaries[i] {
+				t.Fatalf("%s: %v", line, got)
+			}
+		}
+		i := 0
+		for o := range string(bs) {
+			if o == boundaries[i+1] {
+				i++
+			}
+			if r.isGraphemeBoundary(o) != (o == boundaries[i]) {
+				t.Fatalf("%s: boundary at %d", line, o)
+			}
+			if r.NextGraphemeBoundary(o) != boundaries[i+1] {
+				t.Fatalf("%s: next %d", line, o)
+			}
+			if prev := r.PrevGraphemeBoundary(o); o > 0 && prev != boundaries[max(i-1, 0)] && prev != boundaries[i] {
+				t.Fatalf("%s: prev %d", line, o)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGraphemeBreakTest(t *testing.T) {
+	testGraphemeBreakFile(t, "testdata/grapheme_break_test.txt")
+	// the UCD file can be dropped in testdata to run the official cases
+	if _, err := os.Stat("testdata/GraphemeBreakTest.txt"); err == nil {
+		testGraphemeBreakFile(t, "testdata/GraphemeBreakTest.txt")
+	}
+}
+
+func TestRIParity(t *testing.T) {
+	s := strings.Repeat("🇺", 5) + "a" + strings.Repeat("🇫", 4) + "\xf0\x9f\x87" + strings.Repeat("🇫", 3) + "\xf0🇫"
+	r := NewFromBytes([]byte(s))
+	for o := 0; o <= len(s); o++ {
+		n := 0
+		for p := o; p >= 4 && (s[p-4:p] == "🇺" || s[p-4:p] == "🇫"); p -= 4 {
+			n++
+		}
+		if r.riParity(o) != n%2 {
+			t.Fatalf("%d", o)
+		}
+	}
+}
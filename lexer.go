@@ -0,0 +1,108 @@
+package rope
+
+// Token is a lexeme found by a LexFunc, Start and End are relative to the chunk it was reported in.
+// Start may be negative for a lexeme that began in a previous chunk.
+type Token struct {
+	Start int
+	End   int
+	Kind  int
+}
+
+// LexFunc lexes chunk starting in state, returning the state at its end and the tokens it completes.
+// Chunks are rope leaves, so lexemes crossing chunk boundaries must be carried in the state.
+type LexFunc[S comparable] func(state S, chunk []byte) (S, []Token)
+
+// Lexer lexes ropes incrementally. Each node caches the result of its last input state, one entry
+// per lexer, so after an edit only the new nodes are lexed, and the nodes after them as long as
+// their input state differs from the one before the edit.
+type Lexer[S comparable] struct {
+	initial S
+	fn      LexFunc[S]
+}
+
+type lexKey[S comparable] struct {
+	lexer *Lexer[S]
+}
+
+type lexEntry[S comparable] struct {
+	in     S // the state the node was lexed from
+	state  S
+	tokens []Token // leaves only
+}
+
+// NewLexer returns a lexer running fn from the initial state
+func NewLexer[S comparable](initial S, fn LexFunc[S]) *Lexer[S] {
+	return &Lexer[S]{
+		initial: initial,
+		fn:      fn,
+	}
+}
+
+// lex returns the cached result of lexing node from state
+func (l *Lexer[S]) lex(node *Rope, state S) lexEntry[S] {
+	key := lexKey[S]{l}
+	if v, ok := node.cachedSummary(key); ok && v.(lexEntry[S]).in == state {
+		return v.(lexEntry[S])
+	}
+	e := lexEntry[S]{in: state}
+	switch {
+	case len(node.content) > 0:
+		e.state, e.tokens = l.fn(state, node.content)
+	case node.isLeaf():
+		e.state = state
+	default:
+		e.state = state
+		if node.left != nil {
+			e.state = l.lex(node.left, e.state).state
+		}
+		if node.right != nil {
+			e.state = l.lex(node.right, e.state).state
+		}
+	}
+	node.cacheSummary(key, e)
+	return e
+}
+
+// State returns the state at the end of r
+func (l *Lexer[S]) State(r *Rope) S {
+	if r == nil {
+		return l.initial
+	}
+	return l.lex(r, l.initial).state
+}
+
+// Tokens calls fn with the tokens overlapping the bytes from start to end, offsets being relative to r.
+// Tokens are reported with the leaf they end in, only the leaves overlapping the range are lexed.
+func (l *Lexer[S]) Tokens(r *Rope, start, end int, fn func(Token) bool) {
+	l.tokens(r, 0, l.initial, start, end, fn)
+}
+
+func (l *Lexer[S]) tokens(node *Rope, offset int, state S, start, end int, fn func(Token) bool) (S, bool) {
+	if node == nil {
+		return state, true
+	}
+	if offset >= end {
+		return state, false
+	}
+	if offset+node.Len() <= start { // before the range
+		return l.lex(node, state).state, true
+	}
+	if !node.isLeaf() {
+		state, ok := l.tokens(node.left, offset, state, start, end, fn)
+		if !ok {
+			return state, false
+		}
+		return l.tokens(node.right, offset+node.weight, state, start, end, fn)
+	}
+	e := l.lex(node, state)
+	for _, t := range e.tokens {
+		t.Start += offset
+		t.End += offset
+		if t.End > start && t.Start < end {
+			if !fn(t) {
+				return e.state, false
+			}
+		}
+	}
+	return e.state, true
+}
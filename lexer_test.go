@@ -0,0 +1,111 @@
+package rope
+
+import (
+	"bytes"
+	"testing"
+)
+
+// lexStrings reports double quoted strings, the state is the length of the open string plus one
+func lexStrings(state int, chunk []byte) (int, []Token) {
+	var tokens []Token
+	for i, b := range chunk {
+		switch {
+		case state > 0 && b == '"':
+			tokens = append(tokens, Token{i - state, i + 1, 1})
+			state = 0
+		case state > 0:
+			state++
+		case b == '"':
+			state = 1
+		}
+	}
+	return state, tokens
+}
+
+func TestLexer(t *testing.T) {
+	calls := 0
+	lexer := NewLexer(0, func(state int, chunk []byte) (int, []Token) {
+		calls++
+		return lexStrings(state, chunk)
+	})
+	check := func(r *Rope) {
+		state, expected := lexStrings(0, r.Bytes())
+		if lexer.State(r) != state {
+			t.Fatal()
+		}
+		var tokens []Token
+		lexer.Tokens(r, 0, r.Len(), func(tk Token) bool {
+			tokens = append(tokens, tk)
+			return true
+		})
+		if len(tokens) != len(expected) {
+			t.Fatal()
+		}
+		for i, tk := range tokens {
+			if tk != expected[i] {
+				t.Fatal()
+			}
+		}
+		n := 0
+		lexer.Tokens(r, 100, 200, func(tk Token) bool {
+			if tk.End <= 100 || tk.Start >= 200 {
+				t.Fatal()
+			}
+			n++
+			return true
+		})
+		for _, tk := range expected {
+			if tk.End > 100 && tk.Start < 200 {
+				n--
+			}
+		}
+		if n > 0 {
+			t.Fatal()
+		}
+	}
+	r := NewFromBytes(bytes.Repeat([]byte(`foo "bar baz" qux `), 200))
+	check(r)
+	leaves := 0
+	r.iterNodes(func(n *Rope) bool {
+		if n.isLeaf() {
+			leaves++
+		}
+		return true
+	})
+	if calls != leaves {
+		t.Fatal()
+	}
+	// the state converges right after an edit not changing it
+	calls = 0
+	r2 := r.Insert(1000, []byte("xx"))
+	check(r2)
+	if calls > 8 {
+		t.Fatal(calls)
+	}
+	// an unbalanced quote changes the state until the end
+	calls = 0
+	r3 := r.Insert(1000, []byte(`"`))
+	check(r3)
+	if calls < leaves/2 {
+		t.Fatal(calls)
+	}
+	check(NewFromBytes(nil))
+}
+
+func TestLexerCacheOneEntry(t *testing.T) {
+	l := NewLexer(0, func(state int, chunk []byte) (int, []Token) {
+		return state + len(chunk), nil
+	})
+	r := NewFromBytes([]byte("the quick brown fox jumps over the lazy dog"))
+	for i := 0; i < 20; i++ {
+		if l.lex(r, i).state != i+r.Len() {
+			t.Fatal()
+		}
+	}
+	r.iterNodes(func(node *Rope) bool {
+		if table, _ := node.summaries.Load().(*summaryTable); table == nil || len(table.entries) != 1 {
+			t.Fatal()
+		}
+		return true
+	})
+}
@@ -0,0 +1,169 @@
+package rope
+
+// LineEnding is a style of line break
+type LineEnding int
+
+const (
+	LineEndingLF   LineEnding = iota // "\n"
+	LineEndingCRLF                   // "\r\n"
+	LineEndingCR                     // "\r"
+)
+
+func (e LineEnding) bytes() []byte {
+	switch e {
+	case LineEndingCRLF:
+		return []byte("\r\n")
+	case LineEndingCR:
+		return []byte("\r")
+	}
+	return []byte("\n")
+}
+
+// lineEndingSummary counts line breaks, a "\r\n" split across leaves is counted once
+type lineEndingSummary struct {
+	n        int
+	lf       int
+	crlf     int
+	cr       int
+	startsLF bool
+	endsCR   bool
+}
+
+func (s lineEndingSummary) breaks() int {
+	return s.lf + s.crlf + s.cr
+}
+
+func (s lineEndingSummary) count(e LineEnding) int {
+	switch e {
+	case LineEndingCRLF:
+		return s.crlf
+	case LineEndingCR:
+		return s.cr
+	}
+	return s.lf
+}
+
+type lineEndingMeasure struct{}
+
+func (lineEndingMeasure) Summarize(bs []byte) (s lineEndingSummary) {
+	s.n = len(bs)
+	if len(bs) == 0 {
+		return
+	}
+	s.startsLF = bs[0] == '\n'
+	s.endsCR = bs[len(bs)-1] == '\r'
+	for i, b := range bs {
+		switch {
+		case b == '\n' && i > 0 && bs[i-1] == '\r':
+			s.crlf++
+		case b == '\n':
+			s.lf++
+		case b == '\r' && (i+1 == len(bs) || bs[i+1] != '\n'):
+			s.cr++
+		}
+	}
+	return
+}
+
+func (lineEndingMeasure) Combine(a, b lineEndingSummary) lineEndingSummary {
+	if a.n == 0 {
+		return b
+	}
+	if b.n == 0 {
+		return a
+	}
+	s := lineEndingSummary{
+		n:        a.n + b.n,
+		lf:       a.lf + b.lf,
+		crlf:     a.crlf + b.crlf,
+		cr:       a.cr + b.cr,
+		startsLF: a.startsLF,
+		endsCR:   b.endsCR,
+	}
+	if a.endsCR && b.startsLF { // a "\r\n" across the boundary
+		s.cr--
+		s.lf--
+		s.crlf++
+	}
+	return s
+}
+
+// DetectLineEnding returns the most used line ending, LineEndingLF if there is no line break
+func (r *Rope) DetectLineEnding() LineEnding {
+	s := Summary(r, lineEndingMeasure{})
+	switch {
+	case s.crlf > s.lf && s.crlf >= s.cr:
+		return LineEndingCRLF
+	case s.cr > s.lf && s.cr > s.crlf:
+		return LineEndingCR
+	}
+	return LineEndingLF
+}
+
+// NormalizeLineEndings returns a rope with all line breaks replaced by style
+func (r *Rope) NormalizeLineEndings(style LineEnding) *Rope {
+	s := Summary(r, lineEndingMeasure{})
+	if s.breaks() == s.count(style) {
+		return r
+	}
+	sep := style.bytes()
+	buf := make([]byte, 0, s.n+s.breaks()*(len(sep)-1))
+	cr := false // the last chunk ended with '\r'
+	r.Iter(0, func(bs []byte) bool {
+		start := 0
+		for i, b := range bs {
+			switch b {
+			case '\n':
+				buf = append(buf, bs[start:i]...)
+				if !cr { // the '\n' of "\r\n" was replaced with the '\r'
+					buf = append(buf, sep...)
+				}
+				start = i + 1
+			case '\r':
+				buf = append(buf, bs[start:i]...)
+				buf = append(buf, sep...)
+				start = i + 1
+			}
+			cr = b == '\r'
+		}
+		buf = append(buf, bs[start:]...)
+		return true
+	})
+	return newFromBytes(buf, r.config())
+}
+
+// crlfAt reports whether offset is between the '\r' and '\n' of a "\r\n"
+func (r *Rope) crlfAt(offset int) bool {
+	return offset > 0 && offset < r.Len() && r.Index(offset-1) == '\r' && r.Index(offset) == '\n'
+}
+
+// LineCount returns the number of lines, "\n", "\r\n" and "\r" ending a line
+func (r *Rope) LineCount() int {
+	return Summary(r, lineEndingMeasure{}).breaks() + 1
+}
+
+// LineStart returns the offset of the zero based line, Len if line is past the end
+func (r *Rope) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	off := SeekBy(r, lineEndingMeasure{}, func(s lineEndingSummary) bool {
+		return s.breaks() >= line
+	})
+	if off < 0 {
+		return r.Len()
+	}
+	if r.crlfAt(off) {
+		off++
+	}
+	return off
+}
+
+// LineAt returns the zero based line containing offset, line breaks belonging to the line they end
+func (r *Rope) LineAt(offset int) int {
+	line := PrefixSummary(r, lineEndingMeasure{}, offset).breaks()
+	if r.crlfAt(offset) {
+		line--
+	}
+	return line
+}
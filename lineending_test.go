@@ -0,0 +1,61 @@
+package rope
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLineEndings(t *testing.T) {
+	bs := bytes.Repeat([]byte("foo\r\nbar\nbaz\rqux\r\n\r\r\n\n"), 64)
+	r := NewFromBytes(bs)
+	// naive line starts
+	starts := []int{0}
+	for i := 0; i < len(bs); i++ {
+		switch {
+		case bs[i] == '\r' && i+1 < len(bs) && bs[i+1] == '\n':
+			i++
+			starts = append(starts, i+1)
+		case bs[i] == '\r', bs[i] == '\n':
+			starts = append(starts, i+1)
+		}
+	}
+	if r.LineCount() != len(starts) {
+		t.Fatal()
+	}
+	for i, start := range starts {
+		if r.LineStart(i) != start {
+			t.Fatal(i)
+		}
+	}
+	if r.LineStart(len(starts)) != len(bs) {
+		t.Fatal()
+	}
+	line := 0
+	for i := 0; i <= len(bs); i++ {
+		for line+1 < len(starts) && starts[line+1] <= i {
+			line++
+		}
+		if r.LineAt(i) != line {
+			t.Fatal(i)
+		}
+	}
+	if r.DetectLineEnding() != LineEndingCRLF {
+		t.Fatal()
+	}
+	for style, sep := range map[LineEnding]string{LineEndingLF: "\n", LineEndingCRLF: "\r\n", LineEndingCR: "\r"} {
+		expected := bytes.Repeat([]byte("foo"+sep+"bar"+sep+"baz"+sep+"qux"+sep+sep+sep+sep), 64)
+		r2 := r.NormalizeLineEndings(style)
+		if !bytes.Equal(r2.Bytes(), expected) {
+			t.Fatal()
+		}
+		if r2.DetectLineEnding() != style || r2.LineCount() != len(starts) {
+			t.Fatal()
+		}
+		if r2.NormalizeLineEndings(style) != r2 {
+			t.Fatal()
+		}
+	}
+	if NewFromBytes(nil).DetectLineEnding() != LineEndingLF || NewFromBytes(nil).LineCount() != 1 {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,296 @@
+package rope
+
+import "math/rand"
+
+// Gravity decides which side of text inserted at its offset a mark stays on
+type Gravity uint8
+
+const (
+	// GravityLeft keeps the mark before text inserted at its offset
+	GravityLeft Gravity = iota
+	// GravityRight moves the mark after text inserted at its offset
+	GravityRight
+)
+
+// MarkID identifies a mark in a Marks set
+type MarkID int
+
+// Marks is a set of offsets into a rope that follow its edits.
+// Marks are kept in a treap ordered by offset then gravity, shifting the marks after an edit is a lazy
+// update of a subtree so edits cost O(log n) plus O(log n) per mark inside a deleted range.
+type Marks struct {
+	rope   *Rope
+	root   *markNode
+	nodes  map[MarkID]*markNode
+	nextID MarkID
+	rand   *rand.Rand
+}
+
+type markNode struct {
+	id       MarkID
+	offset   int
+	add      int // pending shift of the subtree, this node included
+	gravity  Gravity
+	drop     bool // dropped instead of collapsed when its text is deleted
+	priority uint32
+	left     *markNode
+	right    *markNode
+	parent   *markNode
+}
+
+// NewMarks returns an empty mark set for r
+func NewMarks(r *Rope) *Marks {
+	if r == nil {
+		r = NewFromBytes(nil)
+	}
+	return &Marks{
+		rope:  r,
+		nodes: make(map[MarkID]*markNode),
+		rand:  rand.New(rand.NewSource(1)),
+	}
+}
+
+// Rope returns the version the marks refer to
+func (m *Marks) Rope() *Rope {
+	return m.rope
+}
+
+// Len returns the number of marks
+func (m *Marks) Len() int {
+	return len(m.nodes)
+}
+
+func (n *markNode) push() {
+	if n.add == 0 {
+		return
+	}
+	n.offset += n.add
+	if n.left != nil {
+		n.left.add += n.add
+	}
+	if n.right != nil {
+		n.right.add += n.add
+	}
+	n.add = 0
+}
+
+func (n *markNode) setLeft(c *markNode) {
+	n.left = c
+	if c != nil {
+		c.parent = n
+	}
+}
+
+func (n *markNode) setRight(c *markNode) {
+	n.right = c
+	if c != nil {
+		c.parent = n
+	}
+}
+
+// splitMarks splits t into the nodes satisfying less and the rest, less must be monotonic in order
+func splitMarks(t *markNode, less func(*markNode) bool) (l, r *markNode) {
+	if t == nil {
+		return
+	}
+	t.push()
+	if less(t) {
+		a, b := splitMarks(t.right, less)
+		t.setRight(a)
+		if b != nil {
+			b.parent = nil
+		}
+		t.parent = nil
+		return t, b
+	}
+	a, b := splitMarks(t.left, less)
+	t.setLeft(b)
+	if a != nil {
+		a.parent = nil
+	}
+	t.parent = nil
+	return a, t
+}
+
+func mergeMarks(l, r *markNode) *markNode {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.priority > r.priority {
+		l.push()
+		l.setRight(mergeMarks(l.right, r))
+		return l
+	}
+	r.push()
+	r.setLeft(mergeMarks(l, r.left))
+	return r
+}
+
+// before returns a predicate selecting the marks ordered before offset with gravity g
+func before(offset int, g Gravity) func(*markNode) bool {
+	return func(n *markNode) bool {
+		return n.offset < offset || n.offset == offset && n.gravity < g
+	}
+}
+
+func (m *Marks) insertNode(n *markNode) {
+	l, r := splitMarks(m.root, before(n.offset, n.gravity+1))
+	m.root = mergeMarks(mergeMarks(l, n), r)
+	m.root.parent = nil
+}
+
+func (m *Marks) clamp(offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	if l := m.rope.Len(); offset > l {
+		return l
+	}
+	return offset
+}
+
+// Add adds a mark at offset. Marks with drop set are removed when their text is deleted,
+// others collapse to the start of the deleted range.
+func (m *Marks) Add(offset int, gravity Gravity, drop bool) MarkID {
+	m.nextID++
+	n := &markNode{
+		id:       m.nextID,
+		offset:   m.clamp(offset),
+		gravity:  gravity,
+		drop:     drop,
+		priority: m.rand.Uint32(),
+	}
+	m.nodes[n.id] = n
+	m.insertNode(n)
+	return n.id
+}
+
+// Offset returns the current offset of a mark, false if it was removed or dropped
+func (m *Marks) Offset(id MarkID) (int, bool) {
+	n, ok := m.nodes[id]
+	if !ok {
+		return 0, false
+	}
+	offset := n.offset
+	for p := n; p != nil; p = p.parent {
+		offset += p.add
+	}
+	return offset, true
+}
+
+// Remove removes a mark
+func (m *Marks) Remove(id MarkID) {
+	n, ok := m.nodes[id]
+	if !ok {
+		return
+	}
+	delete(m.nodes, id)
+	// apply the pending shifts above n
+	var path []*markNode
+	for p := n; p != nil; p = p.parent {
+		path = append(path, p)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].push()
+	}
+	c := mergeMarks(n.left, n.right)
+	p := n.parent
+	switch {
+	case p == nil:
+		m.root = c
+		if c != nil {
+			c.parent = nil
+		}
+	case p.left == n:
+		p.setLeft(c)
+	default:
+		p.setRight(c)
+	}
+}
+
+// Iter calls fn with each mark in increasing offset order
+func (m *Marks) Iter(fn func(id MarkID, offset int) bool) {
+	var iter func(n *markNode) bool
+	iter = func(n *markNode) bool {
+		if n == nil {
+			return true
+		}
+		n.push()
+		return iter(n.left) && fn(n.id, n.offset) && iter(n.right)
+	}
+	iter(m.root)
+}
+
+// Insert inserts bs at n in the rope and moves the marks after it
+func (m *Marks) Insert(n int, bs []byte) {
+	n = m.clamp(n)
+	m.rope = m.rope.Insert(n, bs)
+	m.shiftInsert(n, len(bs))
+}
+
+func (m *Marks) shiftInsert(n, l int) {
+	if l == 0 {
+		return
+	}
+	a, b := splitMarks(m.root, before(n, GravityRight))
+	if b != nil {
+		b.add += l
+	}
+	m.root = mergeMarks(a, b)
+}
+
+// Delete deletes l bytes at n in the rope, marks inside the range are collapsed to n or dropped
+func (m *Marks) Delete(n, l int) {
+	n = m.clamp(n)
+	if n+l > m.rope.Len() {
+		l = m.rope.Len() - n
+	}
+	if l <= 0 {
+		return
+	}
+	m.rope = m.rope.Delete(n, l)
+	m.shiftDelete(n, l)
+}
+
+func (m *Marks) shiftDelete(n, l int) {
+	a, rest := splitMarks(m.root, before(n, GravityLeft))
+	mid, c := splitMarks(rest, before(n+l, GravityLeft))
+	if c != nil {
+		c.add -= l
+	}
+	m.root = mergeMarks(a, c)
+	if m.root != nil {
+		m.root.parent = nil
+	}
+	// the marks from n to n+l are reinserted at n to keep the gravity order
+	var collect func(t *markNode)
+	collect = func(t *markNode) {
+		if t == nil {
+			return
+		}
+		t.push()
+		left, right := t.left, t.right
+		t.left, t.right, t.parent = nil, nil, nil
+		collect(left)
+		collect(right)
+		if t.offset > n && t.drop {
+			delete(m.nodes, t.id)
+			return
+		}
+		t.offset = n
+		m.insertNode(t)
+	}
+	collect(mid)
+}
+
+// ApplyEdits applies edits to the rope and moves the marks, see Rope.ApplyEdits
+func (m *Marks) ApplyEdits(edits []Edit) {
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		m.Delete(e.Offset, e.Length)
+		m.Insert(e.Offset, e.Text)
+	}
+}
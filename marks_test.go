@@ -0,0 +1,117 @@
+package rope
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestMarks(t *testing.T) {
+	type mark struct {
+		offset  int
+		gravity Gravity
+		drop    bool
+		removed bool
+	}
+	bs := bytes.Repeat([]byte("foobarbaz"), 32)
+	m := NewMarks(NewFromBytes(bs))
+	expected := make(map[MarkID]*mark)
+	rnd := rand.New(rand.NewSource(42))
+	check := func() {
+		if !bytes.Equal(m.Rope().Bytes(), bs) {
+			t.Fatalf("%q\n%q", m.Rope().Bytes(), bs)
+		}
+		n := 0
+		for id, e := range expected {
+			offset, ok := m.Offset(id)
+			if ok == e.removed {
+				t.Fatal()
+			}
+			if !ok {
+				continue
+			}
+			n++
+			if offset != e.offset {
+				t.Fatalf("%d %d %d", id, offset, e.offset)
+			}
+		}
+		if m.Len() != n {
+			t.Fatal()
+		}
+		last := 0
+		m.Iter(func(id MarkID, offset int) bool {
+			n--
+			if offset < last || offset != expected[id].offset {
+				t.Fatal()
+			}
+			last = offset
+			return true
+		})
+		if n != 0 {
+			t.Fatal()
+		}
+	}
+	insert := func(n int, text []byte) {
+		bs = append(bs[:n:n], append(text, bs[n:]...)...)
+		for _, e := range expected {
+			if e.offset > n || e.offset == n && e.gravity == GravityRight {
+				e.offset += len(text)
+			}
+		}
+	}
+	del := func(n, l int) {
+		bs = append(bs[:n:n], bs[n+l:]...)
+		for _, e := range expected {
+			switch {
+			case e.offset >= n+l:
+				e.offset -= l
+			case e.offset > n && e.drop:
+				e.removed = true
+			case e.offset > n:
+				e.offset = n
+			}
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		n := rnd.Intn(len(bs) + 1)
+		switch rnd.Intn(5) {
+		case 0:
+			e := &mark{
+				offset:  n,
+				gravity: Gravity(rnd.Intn(2)),
+				drop:    rnd.Intn(2) == 0,
+			}
+			expected[m.Add(e.offset, e.gravity, e.drop)] = e
+		case 1:
+			text := bytes.Repeat([]byte("x"), rnd.Intn(5))
+			m.Insert(n, text)
+			insert(n, text)
+		case 2:
+			l := rnd.Intn(len(bs)-n+1) / 4
+			m.Delete(n, l)
+			del(n, l)
+		case 3:
+			if id := MarkID(rnd.Intn(int(m.nextID) + 1)); expected[id] != nil {
+				m.Remove(id)
+				expected[id].removed = true
+			}
+		case 4:
+			if n < 2 {
+				continue
+			}
+			edits := []Edit{
+				{Offset: n / 2, Length: 1, Text: []byte("yy")},
+				{Offset: n, Length: 0, Text: []byte("z")},
+			}
+			r := m.Rope().ApplyEdits(edits)
+			m.ApplyEdits(edits)
+			insert(n, []byte("z"))
+			del(n/2, 1)
+			insert(n/2, []byte("yy"))
+			if !bytes.Equal(r.Bytes(), bs) {
+				t.Fatal()
+			}
+		}
+		check()
+	}
+}
@@ -0,0 +1,168 @@
+package rope
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"sync/atomic"
+)
+
+// Measure describes a monoid summary of rope content.
+// Summarize must be a homomorphism over byte concatenation, that is
+// Summarize(a+b) == Combine(Summarize(a), Summarize(b)) for any split point,
+// because leaf boundaries are arbitrary. Summarize(nil) is the identity.
+type Measure[S any] interface {
+	Summarize(leaf []byte) S
+	Combine(a, b S) S
+}
+
+// ComparableMeasure is a Measure usable as a key of the summary cache
+type ComparableMeasure[S any] interface {
+	Measure[S]
+	comparable
+}
+
+// maxCachedSummaries bounds the summaries cached per node for each measure type, the least
+// recently used is evicted first. Measures of different types do not evict each other, so
+// the built-in ones stay cached whatever other measures are used.
+const maxCachedSummaries = 8
+
+type summaryEntry struct {
+	key   any
+	value any
+	used  atomic.Uint64 // tick of the last use
+}
+
+type summaryTable struct {
+	entries []*summaryEntry
+	tick    atomic.Uint64
+}
+
+func (r *Rope) cachedSummary(key any) (any, bool) {
+	t, _ := r.summaries.Load().(*summaryTable)
+	if t == nil {
+		return nil, false
+	}
+	for _, e := range t.entries {
+		if e.key == key {
+			e.used.Store(t.tick.Add(1))
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// cacheSummary caches value under key, which must be comparable
+func (r *Rope) cacheSummary(key any, value any) {
+	kind := reflect.TypeOf(key)
+	for {
+		old, _ := r.summaries.Load().(*summaryTable)
+		t := new(summaryTable)
+		if old != nil {
+			t.tick.Store(old.tick.Load())
+			t.entries = make([]*summaryEntry, 0, len(old.entries)+1)
+			var lru *summaryEntry // least recently used entry of the same type
+			n := 0
+			for _, e := range old.entries {
+				if e.key == key {
+					continue
+				}
+				t.entries = append(t.entries, e)
+				if reflect.TypeOf(e.key) == kind {
+					n++
+					if lru == nil || e.used.Load() < lru.used.Load() {
+						lru = e
+					}
+				}
+			}
+			if n >= maxCachedSummaries {
+				t.entries = slices.DeleteFunc(t.entries, func(e *summaryEntry) bool {
+					return e == lru
+				})
+			}
+		}
+		e := &summaryEntry{key: key, value: value}
+		e.used.Store(t.tick.Add(1))
+		t.entries = append(t.entries, e)
+		if old == nil {
+			if r.summaries.CompareAndSwap(nil, t) {
+				return
+			}
+		} else if r.summaries.CompareAndSwap(old, t) {
+			return
+		}
+	}
+}
+
+func (r *Rope) isLeaf() bool {
+	return len(r.content) > 0 || (r.left == nil && r.right == nil)
+}
+
+// Summary returns the summary of the whole rope under measure m.
+// Summaries are cached per node, so only nodes created since the last call are summarized.
+// A node keeps a few summaries per measure type, the least recently used are dropped.
+func Summary[S any, M ComparableMeasure[S]](r *Rope, m M) S {
+	if r == nil {
+		return m.Summarize(nil)
+	}
+	if v, ok := r.cachedSummary(m); ok {
+		return v.(S)
+	}
+	var s S
+	if r.isLeaf() {
+		s = m.Summarize(r.content)
+	} else {
+		s = m.Combine(Summary(r.left, m), Summary(r.right, m))
+	}
+	r.cacheSummary(m, s)
+	return s
+}
+
+// PrefixSummary returns the summary of the first n bytes of the rope
+func PrefixSummary[S any, M ComparableMeasure[S]](r *Rope, m M, n int) S {
+	acc := m.Summarize(nil)
+	for r != nil && n > 0 {
+		if r.isLeaf() {
+			if n > len(r.content) {
+				n = len(r.content)
+			}
+			return m.Combine(acc, m.Summarize(r.content[:n]))
+		}
+		if n >= r.weight {
+			acc = m.Combine(acc, Summary(r.left, m))
+			n -= r.weight
+			r = r.right
+		} else {
+			r = r.left
+		}
+	}
+	return acc
+}
+
+// SeekBy returns the smallest offset n such that pred holds for the summary of the first n bytes,
+// or -1 if pred does not hold for the whole rope. pred must be monotone.
+func SeekBy[S any, M ComparableMeasure[S]](r *Rope, m M, pred func(S) bool) int {
+	acc := m.Summarize(nil)
+	if pred(acc) {
+		return 0
+	}
+	if !pred(Summary(r, m)) {
+		return -1
+	}
+	offset := 0
+	for !r.isLeaf() {
+		s := m.Combine(acc, Summary(r.left, m))
+		if pred(s) {
+			r = r.left
+		} else {
+			acc = s
+			offset += r.weight
+			r = r.right
+		}
+	}
+	content := r.content
+	i := sort.Search(len(content), func(i int) bool {
+		return pred(m.Combine(acc, m.Summarize(content[:i+1])))
+	})
+	return offset + i + 1
+}
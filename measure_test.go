@@ -0,0 +1,172 @@
+package rope
+
+import (
+	"bytes"
+	"testing"
+)
+
+type lineCount struct{}
+
+func (lineCount) Summarize(bs []byte) int {
+	return bytes.Count(bs, []byte("\n"))
+}
+
+func (lineCount) Combine(a, b int) int {
+	return a + b
+}
+
+func TestSummary(t *testing.T) {
+	bs := bytes.Repeat([]byte("foo\nbar\nbaz"), 64)
+	r := NewFromBytes(bs)
+	if Summary(r, lineCount{}) != bytes.Count(bs, []byte("\n")) {
+		t.Fatal()
+	}
+	for i := 0; i <= len(bs); i += 7 {
+		r1, r2 := r.Split(i)
+		if Summary(r1, lineCount{}) != bytes.Count(bs[:i], []byte("\n")) {
+			t.Fatal()
+		}
+		if Summary(r2, lineCount{}) != bytes.Count(bs[i:], []byte("\n")) {
+			t.Fatal()
+		}
+		if PrefixSummary(r, lineCount{}, i) != bytes.Count(bs[:i], []byte("\n")) {
+			t.Fatal()
+		}
+		r3 := r.Insert(i, []byte("\n\n"))
+		if Summary(r3, lineCount{}) != bytes.Count(bs, []byte("\n"))+2 {
+			t.Fatal()
+		}
+	}
+	r = NewFromBytes(nil)
+	for i := 0; i < 512; i++ {
+		r = r.Concat(NewFromBytes([]byte("a\n")))
+	}
+	if Summary(r, lineCount{}) != 512 {
+		t.Fatal()
+	}
+}
+
+func TestSeekBy(t *testing.T) {
+	bs := bytes.Repeat([]byte("foo\nbar\nbaz"), 64)
+	r := NewFromBytes(bs)
+	n := 0
+	for i, b := range bs {
+		if b != '\n' {
+			continue
+		}
+		n++
+		off := SeekBy(r, lineCount{}, func(c int) bool {
+			return c >= n
+		})
+		if off != i+1 {
+			t.Fatal()
+		}
+	}
+	if SeekBy(r, lineCount{}, func(c int) bool {
+		return c > n
+	}) != -1 {
+		t.Fatal()
+	}
+	if SeekBy(r, lineCount{}, func(c int) bool {
+		return true
+	}) != 0 {
+		t.Fatal()
+	}
+}
+
+type byteCount struct {
+	b byte
+}
+
+func (m byteCount) Summarize(bs []byte) int {
+	return bytes.Count(bs, []byte{m.b})
+}
+
+func (byteCount) Combine(a, b int) int {
+	return a + b
+}
+
+func TestSummaryCacheBounded(t *testing.T) {
+	bs := []byte("the quick brown fox jumps over the lazy dog")
+	r := NewFromBytes(bytes.Repeat(bs, 10))
+	for round := 0; round < 3; round++ {
+		for _, b := range bs {
+			if Summary(r, byteCount{b}) != 10*bytes.Count(bs, []byte{b}) {
+				t.Fatal()
+			}
+		}
+	}
+	r.iterNodes(func(node *Rope) bool {
+		if table, _ := node.summaries.Load().(*summaryTable); table != nil && len(table.entries) > maxCachedSummaries {
+			t.Fatal(len(table.entries))
+		}
+		return true
+	})
+}
+
+// countedLen counts the leaves it summarizes, each type argument being a distinct measure type
+type countedLen[T any] struct {
+	calls *int
+}
+
+func (m countedLen[T]) Summarize(bs []byte) int {
+	*m.calls++
+	return len(bs)
+}
+
+func (countedLen[T]) Combine(a, b int) int {
+	return a + b
+}
+
+func TestSummaryCacheManyMeasures(t *testing.T) {
+	r := NewFromBytes(bytes.Repeat([]byte("foo\tbär\n我"), 100))
+	calls := 0
+	measures := []func() int{
+		func() int { return Summary(r, countedLen[int]{&calls}) },
+		func() int { return Summary(r, countedLen[int8]{&calls}) },
+		func() int { return Summary(r, countedLen[int16]{&calls}) },
+		func() int { return Summary(r, countedLen[int32]{&calls}) },
+		func() int { return Summary(r, countedLen[int64]{&calls}) },
+		func() int { return Summary(r, countedLen[uint]{&calls}) },
+		func() int { return Summary(r, countedLen[uint8]{&calls}) },
+		func() int { return Summary(r, countedLen[uint16]{&calls}) },
+		func() int { return Summary(r, countedLen[uint32]{&calls}) },
+		func() int { return Summary(r, countedLen[uint64]{&calls}) },
+		func() int {
+			return r.LineCount() + r.VisualRowCount(80, 4) + r.VisualRowCount(40, 4) + r.VisualColumn(r.Len(), 8)
+		},
+	}
+	for round := 0; round < 3; round++ {
+		calls = 0
+		for _, m := range measures {
+			m()
+		}
+		if round > 0 && calls != 0 {
+			t.Fatal(round, calls)
+		}
+	}
+}
+
+func TestSummaryCacheLRU(t *testing.T) {
+	r := NewFromBytes([]byte("abcdefgh"))
+	for b := byte('a'); b < 'a'+maxCachedSummaries; b++ {
+		Summary(r, byteCount{b})
+	}
+	// a hit refreshes the first entry, so the second one is evicted
+	Summary(r, byteCount{'a'})
+	Summary(r, byteCount{'z'})
+	if _, ok := r.cachedSummary(byteCount{'a'}); !ok {
+		t.Fatal()
+	}
+	if _, ok := r.cachedSummary(byteCount{'b'}); ok {
+		t.Fatal()
+	}
+	// other measure types are not evicted
+	Summary(r, lineCount{})
+	for b := byte('A'); b < 'A'+2*maxCachedSummaries; b++ {
+		Summary(r, byteCount{b})
+	}
+	if _, ok := r.cachedSummary(lineCount{}); !ok {
+		t.Fatal()
+	}
+}
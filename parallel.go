@@ -0,0 +1,169 @@
+package rope
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallelMinLeaves is the number of leaves below which subtrees are built on a single goroutine
+const parallelMinLeaves = 64
+
+// newFromBytesParallel builds the same tree as newFromBytes.
+// The full leaves form perfect subtrees whose sizes are the binary digits of the leaf count,
+// these are built concurrently, at most Workers at a time, and joined in the same order.
+func newFromBytesParallel(bs []byte, cfg *Config[byte]) (ret *Rope) {
+	maxLength := cfg.MaxLengthPerNode
+	leaves := len(bs) / maxLength
+	slots := make([]*Rope, 32)
+	sem := make(chan struct{}, cfg.Workers-1)
+	var wg sync.WaitGroup
+	start := 0
+	for slotIndex := len(slots) - 1; slotIndex >= 0; slotIndex-- {
+		n := 1 << uint(slotIndex)
+		if leaves&n == 0 {
+			continue
+		}
+		slotIndex := slotIndex
+		part := bs[start*maxLength : (start+n)*maxLength]
+		select {
+		case sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots[slotIndex] = buildPerfect(part, n, cfg, sem)
+				<-sem
+			}()
+		default:
+			slots[slotIndex] = buildPerfect(part, n, cfg, sem)
+		}
+		start += n
+	}
+	tailStart := leaves * maxLength
+	if tailStart < len(bs) {
+		ret = &Rope{
+			height:  1,
+			weight:  len(bs) - tailStart,
+			content: bs[tailStart:],
+			cfg:     cfg,
+		}
+	}
+	wg.Wait()
+	for _, c := range slots {
+		if c != nil {
+			if ret == nil {
+				ret = c
+			} else {
+				ret = c.Concat(ret)
+			}
+		}
+	}
+	return
+}
+
+// buildPerfect builds a perfect tree of full leaves, leaves being a power of two.
+// The left halves of large trees are built on another goroutine if sem is not full.
+func buildPerfect(bs []byte, leaves int, cfg *Config[byte], sem chan struct{}) *Rope {
+	maxLength := cfg.MaxLengthPerNode
+	if leaves == 1 {
+		return &Rope{
+			height:  1,
+			weight:  maxLength,
+			content: bs,
+			cfg:     cfg,
+		}
+	}
+	half := leaves / 2
+	var left, right *Rope
+	spawned := false
+	if leaves >= parallelMinLeaves {
+		select {
+		case sem <- struct{}{}:
+			spawned = true
+		default:
+		}
+	}
+	if spawned {
+		done := make(chan struct{})
+		go func() {
+			left = buildPerfect(bs[:half*maxLength], half, cfg, sem)
+			<-sem
+			close(done)
+		}()
+		right = buildPerfect(bs[half*maxLength:], half, cfg, sem)
+		<-done
+	} else {
+		left = buildPerfect(bs[:half*maxLength], half, cfg, sem)
+		right = buildPerfect(bs[half*maxLength:], half, cfg, sem)
+	}
+	return &Rope{
+		height: left.height + 1,
+		weight: half * maxLength,
+		left:   left,
+		right:  right,
+		cfg:    cfg,
+	}
+}
+
+// pieces returns the boundaries of at least n consecutive subtrees of r if possible
+func (r *Rope) pieces(n int) []int {
+	nodes := []*Rope{r}
+	offsets := []int{0}
+	for len(nodes) < n {
+		var nextNodes []*Rope
+		var nextOffsets []int
+		split := false
+		for i, node := range nodes {
+			if node.isLeaf() {
+				nextNodes = append(nextNodes, node)
+				nextOffsets = append(nextOffsets, offsets[i])
+				continue
+			}
+			split = true
+			if node.left != nil {
+				nextNodes = append(nextNodes, node.left)
+				nextOffsets = append(nextOffsets, offsets[i])
+			}
+			if node.right != nil {
+				nextNodes = append(nextNodes, node.right)
+				nextOffsets = append(nextOffsets, offsets[i]+node.weight)
+			}
+		}
+		nodes, offsets = nextNodes, nextOffsets
+		if !split {
+			break
+		}
+	}
+	return append(offsets, r.Len())
+}
+
+// eachPiece calls fn with the consecutive ranges delimited by bounds on workers goroutines
+func eachPiece(bounds []int, workers int, fn func(i, start, end int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i, bounds[i], bounds[i+1])
+			}
+		}()
+	}
+	for i := 0; i < len(bounds)-1; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// storeMin stores v in x if it is smaller than the value of x
+func storeMin(x *atomic.Int64, v int64) {
+	for old := x.Load(); v < old && !x.CompareAndSwap(old, v); old = x.Load() {
+	}
+}
+
+// parallel reports whether bulk operations on r should use multiple goroutines
+func (r *Rope) parallel() bool {
+	cfg := r.config()
+	return cfg.Workers > 1 && r.Len() >= parallelMinLeaves*cfg.MaxLengthPerNode
+}
@@ -0,0 +1,38 @@
+package rope
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParallelBuild(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 64 * 8, 64*8 + 3, 1000*8 + 5, 4096 * 8} {
+		bs := bytes.Repeat([]byte("x"), n)
+		for i := range bs {
+			bs[i] = byte(i)
+		}
+		seq := NewFromBytesWithConfig(bs, &Config[byte]{MaxLengthPerNode: 8})
+		for _, workers := range []int{2, 3, 16} {
+			par := NewFromBytesWithConfig(bs, &Config[byte]{MaxLengthPerNode: 8, Workers: workers})
+			if !par.StructEqual(seq) {
+				t.Fatal()
+			}
+			if !bytes.Equal(par.Bytes(), bs) {
+				t.Fatal()
+			}
+		}
+	}
+}
+
+func TestPieces(t *testing.T) {
+	r := NewFromBytes(bytes.Repeat([]byte("foobar"), 1000))
+	bounds := r.pieces(16)
+	if len(bounds) < 17 || bounds[0] != 0 || bounds[len(bounds)-1] != r.Len() {
+		t.Fatal()
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			t.Fatal()
+		}
+	}
+}
@@ -1,63 +1,83 @@
 package rope
 
 import (
-	"math"
+	"errors"
+	"sync/atomic"
 	"unicode/utf8"
 )
 
+// ErrOutOfRange is returned by the checked operations for offsets outside of the rope
+var ErrOutOfRange = errors.New("rope: offset out of range")
+
 type Rope struct {
-	height   int
-	weight   int
-	left     *Rope
-	right    *Rope
-	content  []byte
-	balanced bool
+	height  int
+	weight  int
+	left    *Rope
+	right   *Rope
+	content []byte
+	cfg     *Config[byte]
+	// the Builder allowed to mutate the node, see Builder
+	owner *builderToken
+	// cached measure summaries, see Summary
+	summaries atomic.Value
 }
 
-var MaxLengthPerNode = 128
-
 // NewFromBytes genearte new rope from bytes
 func NewFromBytes(bs []byte) (ret *Rope) {
+	return NewFromBytesWithConfig(bs, nil)
+}
+
+// NewFromBytesWithConfig generates a new rope using cfg, nil for the default configuration
+func NewFromBytesWithConfig(bs []byte, cfg *Config[byte]) *Rope {
+	return newFromBytes(bs, cfg.normalize(defaultConfig))
+}
+
+// newFromBytes generates a new rope using cfg, which is normalized
+func newFromBytes(bs []byte, cfg *Config[byte]) (ret *Rope) {
+	maxLength := cfg.MaxLengthPerNode
 	if len(bs) == 0 {
 		ret = &Rope{
-			height:   0,
-			weight:   0,
-			content:  bs,
-			balanced: false,
+			height:  0,
+			weight:  0,
+			content: bs,
+			cfg:     cfg,
 		}
 		return
 	}
+	if cfg.Workers > 1 && len(bs)/maxLength >= parallelMinLeaves {
+		return newFromBytesParallel(bs, cfg)
+	}
 	slots := make([]*Rope, 32)
 	var slotIndex int
 	var r *Rope
-	for blockIndex := 0; blockIndex < len(bs)/MaxLengthPerNode; blockIndex++ {
+	for blockIndex := 0; blockIndex < len(bs)/maxLength; blockIndex++ {
 		r = &Rope{
-			height:   1,
-			weight:   MaxLengthPerNode,
-			content:  bs[blockIndex*MaxLengthPerNode : (blockIndex+1)*MaxLengthPerNode],
-			balanced: true,
+			height:  1,
+			weight:  maxLength,
+			content: bs[blockIndex*maxLength : (blockIndex+1)*maxLength],
+			cfg:     cfg,
 		}
 		slotIndex = 0
 		for slots[slotIndex] != nil {
 			r = &Rope{
-				height:   slotIndex + 2,
-				weight:   (1 << uint(slotIndex)) * MaxLengthPerNode,
-				left:     slots[slotIndex],
-				right:    r,
-				balanced: true,
+				height: slotIndex + 2,
+				weight: (1 << uint(slotIndex)) * maxLength,
+				left:   slots[slotIndex],
+				right:  r,
+				cfg:    cfg,
 			}
 			slots[slotIndex] = nil
 			slotIndex++
 		}
 		slots[slotIndex] = r
 	}
-	tailStart := len(bs) / MaxLengthPerNode * MaxLengthPerNode
+	tailStart := len(bs) / maxLength * maxLength
 	if tailStart < len(bs) {
 		ret = &Rope{
-			height:   1,
-			weight:   len(bs) - tailStart,
-			content:  bs[tailStart:],
-			balanced: false,
+			height:  1,
+			weight:  len(bs) - tailStart,
+			content: bs[tailStart:],
+			cfg:     cfg,
 		}
 	}
 	for _, c := range slots {
@@ -72,18 +92,67 @@ func NewFromBytes(bs []byte) (ret *Rope) {
 	return
 }
 
-// Index returns byt at index
+// Compact returns a rope with the same content in full leaves
+func (r *Rope) Compact() *Rope {
+	return newFromBytes(r.Bytes(), r.config())
+}
+
+// Index returns byt at index, it panics with ErrOutOfRange if i is not in [0, Len)
 func (r *Rope) Index(i int) byte {
+	b, err := r.TryIndex(i)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TryIndex returns the byte at index i, or ErrOutOfRange
+func (r *Rope) TryIndex(i int) (byte, error) {
+	if i < 0 || i >= r.Len() {
+		return 0, ErrOutOfRange
+	}
+	return r.index(i), nil
+}
+
+func (r *Rope) index(i int) byte {
 	if i >= r.weight {
-		return r.right.Index(i - r.weight)
+		return r.right.index(i - r.weight)
 	}
 	if r.left != nil { // non leaf
-		return r.left.Index(i)
+		return r.left.index(i)
 	}
 	// leaf
 	return r.content[i]
 }
 
+// clampRange returns the part of the range of l bytes at n inside the rope
+func (r *Rope) clampRange(n, l int) (int, int) {
+	length := r.Len()
+	l = max(l, 0)
+	if n < 0 {
+		l = max(0, l+n)
+		n = 0
+	}
+	n = min(n, length)
+	return n, min(l, length-n)
+}
+
+// checkRange returns ErrOutOfRange if the range of l bytes at n is not inside the rope
+func (r *Rope) checkRange(n, l int) error {
+	if n < 0 || l < 0 || n > r.Len() || l > r.Len()-n {
+		return ErrOutOfRange
+	}
+	return nil
+}
+
+// config returns the configuration of the rope
+func (r *Rope) config() *Config[byte] {
+	if r == nil || r.cfg == nil {
+		return defaultConfig
+	}
+	return r.cfg
+}
+
 // Len returns the length of the rope
 func (r *Rope) Len() int {
 	if r == nil {
@@ -104,93 +173,249 @@ func (r *Rope) Bytes() []byte {
 	return ret
 }
 
-func (r *Rope) Concat(r2 *Rope) (ret *Rope) {
-	ret = &Rope{
-		weight: r.Len(),
-		left:   r,
-		right:  r2,
+// Concat returns r followed by r2. Ropes are AVL trees, the lower one is joined
+// along the spine of the higher one with rotations, in O(log n).
+func (r *Rope) Concat(r2 *Rope) *Rope {
+	return r.concat(r2, r.Len()).mustValidate()
+}
+
+// concat is Concat with the length l of r known
+func (r *Rope) concat(r2 *Rope, l int) *Rope {
+	if r2 == nil || r2.height == 0 {
+		if r == nil {
+			return r2
+		}
+		return r
 	}
-	if ret.left != nil {
-		ret.height = ret.left.height
+	if r == nil || r.height == 0 {
+		return r2
 	}
-	if ret.right != nil && ret.right.height > ret.height {
-		ret.height = ret.right.height
+	var moved int
+	if r, r2, moved = r.mergeLeaves(r2); r2 == nil {
+		return r
 	}
-	if ret.left != nil && ret.left.balanced &&
-		ret.right != nil && ret.right.balanced &&
-		ret.left.height == ret.right.height {
-		ret.balanced = true
+	l += moved
+	return r.join(r2, l)
+}
+
+// join joins two non empty ropes, l being the length of r
+func (r *Rope) join(r2 *Rope, l int) *Rope {
+	switch {
+	case r.height > r2.height+1:
+		return r.newNode(r.left, r.right.join(r2, l-r.weight), r.weight).balance()
+	case r2.height > r.height+1:
+		return r.newNode(r.join(r2.left, l), r2.right, l+r2.weight).balance()
 	}
-	ret.height++
-	// check and rebalance
-	if !ret.balanced {
-		l := int((math.Ceil(math.Log2(float64((ret.Len()/MaxLengthPerNode)+1))) + 1) * 1.5)
-		if ret.height > l {
-			ret = ret.rebalance()
+	return r.newNode(r, r2, l)
+}
+
+func (r *Rope) newNode(left, right *Rope, weight int) *Rope {
+	height := left.height
+	if right.height > height {
+		height = right.height
+	}
+	return &Rope{
+		height: height + 1,
+		weight: weight,
+		left:   left,
+		right:  right,
+		cfg:    r.config(),
+	}
+}
+
+// balance restores the height invariant of a node whose children heights differ by at most 2
+func (r *Rope) balance() *Rope {
+	switch {
+	case r.left.height > r.right.height+1:
+		left := r.left
+		if left.right.height > left.left.height {
+			left = left.rotateLeft()
+		}
+		return r.newNode(left, r.right, r.weight).rotateRight()
+	case r.right.height > r.left.height+1:
+		right := r.right
+		if right.left.height > right.right.height {
+			right = right.rotateRight()
 		}
+		return r.newNode(r.left, right, r.weight).rotateLeft()
 	}
-	return
+	return r
 }
 
-func (r *Rope) rebalance() (ret *Rope) {
-	var currentBytes []byte
-	slots := make([]*Rope, 32)
-	r.iterNodes(func(node *Rope) bool {
-		var balancedNode *Rope
-		iterSubNodes := true
-		if len(currentBytes) == 0 && node.balanced { // balanced, insert to slots
-			balancedNode = node
-			iterSubNodes = false
-		} else { // collect bytes
-			currentBytes = append(currentBytes, node.content...)
-			if len(currentBytes) >= MaxLengthPerNode { // a full leaf
-				balancedNode = &Rope{
-					height:   1,
-					weight:   MaxLengthPerNode,
-					balanced: true,
-					content:  currentBytes[:MaxLengthPerNode],
-				}
-				currentBytes = currentBytes[MaxLengthPerNode:]
-			}
+// rotateLeft turns (a, (b, c)) into ((a, b), c)
+func (r *Rope) rotateLeft() *Rope {
+	right := r.right
+	return r.newNode(r.newNode(r.left, right.left, r.weight), right.right, r.weight+right.weight)
+}
+
+// rotateRight turns ((a, b), c) into (a, (b, c))
+func (r *Rope) rotateRight() *Rope {
+	left := r.left
+	return r.newNode(left.left, r.newNode(left.right, r.right, r.weight-left.weight), left.weight)
+}
+
+func (r *Rope) lastLeaf() *Rope {
+	for !r.isLeaf() {
+		if r.right != nil {
+			r = r.right
+		} else {
+			r = r.left
 		}
-		if balancedNode != nil {
-			slotIndex := balancedNode.height - 1
-			for slots[slotIndex] != nil {
-				balancedNode = &Rope{
-					height:   balancedNode.height + 1,
-					weight:   slots[slotIndex].Len(),
-					left:     slots[slotIndex],
-					right:    balancedNode,
-					balanced: true,
-				}
-				slots[slotIndex] = nil
-				slotIndex++
-			}
-			slots[slotIndex] = balancedNode
+	}
+	return r
+}
+
+func (r *Rope) firstLeaf() *Rope {
+	for !r.isLeaf() {
+		if r.left != nil {
+			r = r.left
+		} else {
+			r = r.right
 		}
-		return iterSubNodes
-	})
-	if len(currentBytes) > 0 {
-		ret = &Rope{
-			height:   1,
-			weight:   len(currentBytes),
-			balanced: false,
-			content:  currentBytes,
+	}
+	return r
+}
+
+// withoutFirstLeaf copies the path to the first leaf, which holds n bytes, removing it
+func (r *Rope) withoutFirstLeaf(n int) *Rope {
+	if r.isLeaf() {
+		return nil
+	}
+	left := r.left.withoutFirstLeaf(n)
+	if left == nil {
+		return r.right
+	}
+	return left.concat(r.right, r.weight-n)
+}
+
+// mergeLeaves moves the first leaf of r2 into the last leaf of r if one of them is shorter than
+// the minimum length and they fit in a leaf, returning the number of bytes moved
+func (r *Rope) mergeLeaves(r2 *Rope) (*Rope, *Rope, int) {
+	cfg := r.config()
+	last, first := r.lastLeaf(), r2.firstLeaf()
+	if len(last.content) == 0 || len(first.content) == 0 ||
+		len(last.content)+len(first.content) > cfg.MaxLengthPerNode ||
+		len(last.content) >= cfg.minLength() && len(first.content) >= cfg.minLength() {
+		return r, r2, 0
+	}
+	return r.appendToLastLeaf(first.content), r2.withoutFirstLeaf(len(first.content)), len(first.content)
+}
+
+// Append returns a rope with bs appended, filling the last leaf before adding new ones
+func (r *Rope) Append(bs []byte) *Rope {
+	if len(bs) == 0 {
+		return r
+	}
+	cfg := r.config()
+	if r.Len() == 0 {
+		return newFromBytes(bs, cfg)
+	}
+	if room := cfg.MaxLengthPerNode - len(r.lastLeaf().content); room > 0 {
+		n := min(room, len(bs))
+		r = r.appendToLastLeaf(bs[:n])
+		bs = bs[n:]
+	}
+	if len(bs) > 0 {
+		r = r.Concat(newFromBytes(bs, cfg))
+	}
+	return r.mustValidate()
+}
+
+// appendToLastLeaf copies the path to the last leaf, appending bs to it
+func (r *Rope) appendToLastLeaf(bs []byte) *Rope {
+	cfg := r.config()
+	if r.isLeaf() {
+		content := cfg.append(cfg.alloc(len(r.content)+len(bs)), r.content...)
+		content = append(content, bs...)
+		return &Rope{
+			height:  1,
+			weight:  len(content),
+			content: content,
+			cfg:     cfg,
 		}
 	}
-	for _, c := range slots {
-		if c != nil {
-			if ret == nil {
-				ret = c
-			} else {
-				ret = c.Concat(ret)
-			}
+	ret := &Rope{
+		height: r.height,
+		weight: r.weight,
+		left:   r.left,
+		right:  r.right,
+		cfg:    cfg,
+	}
+	if r.right != nil {
+		ret.right = r.right.appendToLastLeaf(bs)
+	} else {
+		ret.left = r.left.appendToLastLeaf(bs)
+		ret.weight += len(bs)
+	}
+	return ret
+}
+
+// Prepend returns a rope with bs prepended, filling the first leaf before adding new ones
+func (r *Rope) Prepend(bs []byte) *Rope {
+	if len(bs) == 0 {
+		return r
+	}
+	cfg := r.config()
+	if r.Len() == 0 {
+		return newFromBytes(bs, cfg)
+	}
+	if room := cfg.MaxLengthPerNode - len(r.firstLeaf().content); room > 0 {
+		n := min(room, len(bs))
+		r = r.prependToFirstLeaf(bs[len(bs)-n:])
+		bs = bs[:len(bs)-n]
+	}
+	if len(bs) > 0 {
+		r = newFromBytes(bs, cfg).Concat(r)
+	}
+	return r.mustValidate()
+}
+
+// prependToFirstLeaf copies the path to the first leaf, prepending bs to it
+func (r *Rope) prependToFirstLeaf(bs []byte) *Rope {
+	cfg := r.config()
+	if r.isLeaf() {
+		content := cfg.append(cfg.alloc(len(r.content)+len(bs)), bs...)
+		content = append(content, r.content...)
+		return &Rope{
+			height:  1,
+			weight:  len(content),
+			content: content,
+			cfg:     cfg,
 		}
 	}
-	return
+	ret := &Rope{
+		height: r.height,
+		weight: r.weight,
+		left:   r.left,
+		right:  r.right,
+		cfg:    cfg,
+	}
+	if r.left != nil {
+		ret.left = r.left.prependToFirstLeaf(bs)
+		ret.weight += len(bs)
+	} else {
+		ret.right = r.right.prependToFirstLeaf(bs)
+	}
+	return ret
 }
 
+// Split splits the rope at offset n, clamped to [0, Len]
 func (r *Rope) Split(n int) (out1, out2 *Rope) {
+	n, _ = r.clampRange(n, 0)
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate()
+}
+
+// TrySplit splits the rope at offset n, or returns ErrOutOfRange
+func (r *Rope) TrySplit(n int) (out1, out2 *Rope, err error) {
+	if err = r.checkRange(n, 0); err != nil {
+		return
+	}
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate(), nil
+}
+
+func (r *Rope) split(n int) (out1, out2 *Rope) {
 	if r == nil {
 		return
 	}
@@ -198,34 +423,68 @@ func (r *Rope) Split(n int) (out1, out2 *Rope) {
 		if n > len(r.content) { // offset overflow
 			n = len(r.content)
 		}
-		out1 = NewFromBytes(r.content[:n])
-		out2 = NewFromBytes(r.content[n:])
+		out1 = newFromBytes(r.content[:n], r.config())
+		out2 = newFromBytes(r.content[n:], r.config())
 	} else { // non leaf
 		var r1 *Rope
 		if n >= r.weight { // at right subtree
-			r1, out2 = r.right.Split(n - r.weight)
-			out1 = r.left.Concat(r1)
+			r1, out2 = r.right.split(n - r.weight)
+			out1 = r.left.concat(r1, r.weight)
 		} else { // at left subtree
-			out1, r1 = r.left.Split(n)
-			out2 = r1.Concat(r.right)
+			out1, r1 = r.left.split(n)
+			out2 = r1.concat(r.right, r.weight-n)
 		}
 	}
 	return
 }
 
+// Insert inserts bs at offset n, clamped to [0, Len]
 func (r *Rope) Insert(n int, bs []byte) *Rope {
-	r1, r2 := r.Split(n)
-	return r1.Concat(NewFromBytes(bs)).Concat(r2)
+	if n >= r.Len() {
+		return r.Append(bs)
+	}
+	if n <= 0 {
+		return r.Prepend(bs)
+	}
+	r1, r2 := r.split(n)
+	return r1.Concat(newFromBytes(bs, r.config())).Concat(r2).mustValidate()
+}
+
+// TryInsert inserts bs at offset n, or returns ErrOutOfRange
+func (r *Rope) TryInsert(n int, bs []byte) (*Rope, error) {
+	if err := r.checkRange(n, 0); err != nil {
+		return nil, err
+	}
+	return r.Insert(n, bs), nil
 }
 
+// Delete deletes l bytes at offset n, the part of the range outside of the rope is ignored
 func (r *Rope) Delete(n, l int) *Rope {
-	r1, r2 := r.Split(n)
-	_, r2 = r2.Split(l)
-	return r1.Concat(r2)
+	n, l = r.clampRange(n, l)
+	r1, r2 := r.split(n)
+	_, r2 = r2.split(l)
+	return r1.Concat(r2).mustValidate()
+}
+
+// TryDelete deletes l bytes at offset n, or returns ErrOutOfRange
+func (r *Rope) TryDelete(n, l int) (*Rope, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Delete(n, l), nil
+}
+
+// TrySub returns l bytes at offset n, or ErrOutOfRange
+func (r *Rope) TrySub(n, l int) ([]byte, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Sub(n, l), nil
 }
 
-// Sub returns a substring of the rope
+// Sub returns a substring of the rope, the part of the range outside of the rope is ignored
 func (r *Rope) Sub(n, l int) []byte {
+	n, l = r.clampRange(n, l)
 	ret := make([]byte, l)
 	i := 0
 	r.Iter(n, func(bs []byte) bool {
@@ -248,6 +507,7 @@ func (r *Rope) Iter(offset int, fn func([]byte) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
+		offset = max(offset, 0)
 		if offset < len(r.content) {
 			if !fn(r.content[offset:]) {
 				return false
@@ -275,7 +535,7 @@ func (r *Rope) IterBackward(offset int, fn func([]byte) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
-		content := r.content[:offset]
+		content := r.content[:max(0, min(offset, len(r.content)))]
 		if len(content) == 0 {
 			return true
 		}
@@ -20,7 +20,7 @@ func getRandomBytes(l int) []byte {
 }
 
 func TestMain(m *testing.M) {
-	MaxLengthPerNode = 8
+	defaultConfig = &Config[byte]{MaxLengthPerNode: 8}
 	os.Exit(m.Run())
 }
 
@@ -34,7 +34,7 @@ func TestNewFromBytes(t *testing.T) {
 		weight:  3,
 		content: []byte("foo"),
 	}) {
-		r.Dump()
+		r.Dump(os.Stdout)
 		t.Fatal()
 	}
 
@@ -51,7 +51,7 @@ func TestNewFromBytes(t *testing.T) {
 			content: []byte("z"),
 		},
 	}) {
-		r.Dump()
+		r.Dump(os.Stdout)
 		t.Fatal()
 	}
 }
@@ -253,6 +253,93 @@ func TestSub(t *testing.T) {
 	}
 }
 
+func TestOutOfRange(t *testing.T) {
+	bs := []byte("foobarbaz")
+	r := NewFromBytes(bs)
+	if _, err := r.TryIndex(-1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := r.TryIndex(len(bs)); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if b, err := r.TryIndex(3); err != nil || b != 'b' {
+		t.Fatal()
+	}
+	if _, _, err := r.TrySplit(len(bs) + 1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if r1, r2, err := r.TrySplit(len(bs)); err != nil || r1.Len() != len(bs) || r2.Len() != 0 {
+		t.Fatal()
+	}
+	if _, err := r.TrySub(5, 5); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := r.TrySub(1, -1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if sub, err := r.TrySub(3, 3); err != nil || string(sub) != "bar" {
+		t.Fatal()
+	}
+	if _, err := r.TryInsert(-1, nil); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := r.TryDelete(8, 2); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	// n+l overflows
+	if _, err := r.TrySub(3, math.MaxInt); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := r.TryDelete(math.MaxInt, math.MaxInt); err != ErrOutOfRange {
+		t.Fatal()
+	}
+
+	// unchecked variants clamp
+	func() {
+		defer func() {
+			if recover() != ErrOutOfRange {
+				t.Fatal()
+			}
+		}()
+		r.Index(len(bs))
+	}()
+	if r1, r2 := r.Split(-3); r1.Len() != 0 || !bytes.Equal(r2.Bytes(), bs) {
+		t.Fatal()
+	}
+	if r1, r2 := r.Split(100); !bytes.Equal(r1.Bytes(), bs) || r2.Len() != 0 {
+		t.Fatal()
+	}
+	if string(r.Sub(-3, 6)) != "foo" || len(r.Sub(3, -1)) != 0 || string(r.Sub(6, 100)) != "baz" {
+		t.Fatal()
+	}
+	if string(r.Delete(-3, 6).Bytes()) != "barbaz" || string(r.Delete(6, 100).Bytes()) != "foobar" {
+		t.Fatal()
+	}
+	if string(r.Delete(3, -1).Bytes()) != string(bs) {
+		t.Fatal()
+	}
+	if string(r.Insert(-1, []byte("x")).Bytes()) != "xfoobarbaz" {
+		t.Fatal()
+	}
+	if string(r.Sub(3, math.MaxInt)) != "barbaz" || len(r.Sub(math.MaxInt, math.MaxInt)) != 0 ||
+		string(r.Sub(math.MinInt, math.MaxInt)) != "" || string(r.Delete(6, math.MaxInt).Bytes()) != "foobar" {
+		t.Fatal()
+	}
+	rr := NewFromRunes([]rune("foobarbaz"))
+	if _, err := rr.TryIndex(9); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if string(rr.Sub(-3, 6)) != "foo" || string(rr.Delete(6, 100).Runes()) != "foobar" {
+		t.Fatal()
+	}
+	if _, err := rr.TrySub(3, math.MaxInt); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if string(rr.Sub(3, math.MaxInt)) != "barbaz" || string(rr.Delete(math.MaxInt, math.MaxInt).Runes()) != "foobarbaz" {
+		t.Fatal()
+	}
+}
+
 func TestBalance(t *testing.T) {
 	r := NewFromBytes(nil)
 	n := 4096
@@ -262,12 +349,145 @@ func TestBalance(t *testing.T) {
 	if r.Len() != n {
 		t.Fatal()
 	}
-	maxHeight := int(math.Log2(float64(n/MaxLengthPerNode))+1) * 2
+	maxHeight := int(math.Log2(float64(n/defaultConfig.MaxLengthPerNode))+1) * 2
 	if r.height > maxHeight {
 		t.Fatal()
 	}
 }
 
+func TestAppendPrepend(t *testing.T) {
+	maxLength := defaultConfig.MaxLengthPerNode
+	var expected []byte
+	r := NewFromBytes(nil)
+	var ropes []*Rope
+	var versions [][]byte
+	for i := 0; i < 1024; i++ {
+		c := byte('a' + i%26)
+		if i%2 == 0 {
+			r = r.Append([]byte{c})
+			expected = append(expected, c)
+		} else {
+			r = r.Prepend([]byte{c})
+			expected = append([]byte{c}, expected...)
+		}
+		ropes = append(ropes, r)
+		versions = append(versions, append([]byte(nil), expected...))
+	}
+	if !bytes.Equal(r.Bytes(), expected) {
+		t.Fatal()
+	}
+	if leaves := countLeaves(r); leaves > 1024/maxLength+2 {
+		t.Fatal(leaves)
+	}
+	// older versions are not changed
+	for i, r := range ropes {
+		if !bytes.Equal(r.Bytes(), versions[i]) {
+			t.Fatal()
+		}
+	}
+	bs := bytes.Repeat([]byte("foo"), 10)
+	if !bytes.Equal(r.Append(bs).Prepend(bs).Bytes(), append(append(bs, expected...), bs...)) {
+		t.Fatal()
+	}
+}
+
+// fragment applies random small edits to r
+func fragment(r *Rope, n int) *Rope {
+	rnd := mrand.New(mrand.NewSource(42))
+	for i := 0; i < n; i++ {
+		off := rnd.Intn(r.Len() + 1)
+		if i%2 == 0 {
+			r = r.Insert(off, []byte("x"))
+		} else if off < r.Len() {
+			r = r.Delete(off, 1)
+		}
+	}
+	return r
+}
+
+func countLeaves(r *Rope) (n int) {
+	r.iterNodes(func(node *Rope) bool {
+		if node.isLeaf() {
+			n++
+		}
+		return true
+	})
+	return
+}
+
+func TestMergeLeaves(t *testing.T) {
+	bs := bytes.Repeat([]byte("foobarbaz"), 256)
+	merged := fragment(NewFromBytes(bs), 2000)
+	unmerged := fragment(NewFromBytesWithConfig(bs, &Config[byte]{MaxLengthPerNode: 8, MinLengthPerNode: -1}), 2000)
+	if !bytes.Equal(merged.Bytes(), unmerged.Bytes()) {
+		t.Fatal()
+	}
+	if countLeaves(merged) >= countLeaves(unmerged) {
+		t.Fatal()
+	}
+	compacted := merged.Compact()
+	if !bytes.Equal(compacted.Bytes(), merged.Bytes()) {
+		t.Fatal()
+	}
+	if countLeaves(compacted) != (compacted.Len()+7)/8 {
+		t.Fatal()
+	}
+}
+
+func TestRandomEdits(t *testing.T) {
+	bs := bytes.Repeat([]byte("foobarbaz"), 32)
+	r := NewFromBytes(bs)
+	rnd := mrand.New(mrand.NewSource(42))
+	for i := 0; i < 2000; i++ {
+		n := rnd.Intn(len(bs) + 1)
+		if rnd.Intn(2) == 0 {
+			l := rnd.Intn(len(bs)-n+1) / 4
+			r = r.Delete(n, l)
+			bs = append(bs[:n:n], bs[n+l:]...)
+		} else {
+			text := bytes.Repeat([]byte("x"), rnd.Intn(5))
+			r = r.Insert(n, text)
+			bs = append(bs[:n:n], append(text, bs[n:]...)...)
+		}
+		if !bytes.Equal(r.Bytes(), bs) {
+			t.Fatal()
+		}
+	}
+}
+
+func TestAVL(t *testing.T) {
+	rnd := mrand.New(mrand.NewSource(42))
+	var bs []byte
+	r := NewFromBytes(nil)
+	for i := 0; i < 2000; i++ {
+		n := rnd.Intn(len(bs) + 1)
+		switch rnd.Intn(4) {
+		case 0:
+			text := bytes.Repeat([]byte{byte('a' + i%26)}, rnd.Intn(100))
+			r = r.Insert(n, text)
+			bs = append(bs[:n:n], append(text, bs[n:]...)...)
+		case 1:
+			l := rnd.Intn(len(bs)-n+1) / 4
+			r = r.Delete(n, l)
+			bs = append(bs[:n:n], bs[n+l:]...)
+		case 2:
+			r1, r2 := r.Split(n)
+			if r1.Validate() != nil || r2.Validate() != nil {
+				t.Fatal()
+			}
+			r = r2.Concat(r1)
+			bs = append(bs[n:len(bs):len(bs)], bs[:n]...)
+		case 3:
+			text := bytes.Repeat([]byte{byte('a' + i%26)}, rnd.Intn(2000))
+			r = NewFromBytes(text).Concat(r)
+			bs = append(text, bs...)
+		}
+		if r.Validate() != nil || !bytes.Equal(r.Bytes(), bs) {
+			t.Fatal()
+		}
+	}
+}
+
 func TestIter(t *testing.T) {
 	r := NewFromBytes(bytes.Repeat([]byte("foobarbaz"), 512))
 	r.Iter(0, func([]byte) bool {
@@ -353,7 +573,7 @@ func TestIterBackward(t *testing.T) {
 		}
 		return true
 	})
-	if n != 520 {
+	if n != 2568 {
 		t.Fatal()
 	}
 
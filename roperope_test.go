@@ -1,6 +1,9 @@
 package rope
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestNewFromRopes(t *testing.T) {
 	// nil bytes
@@ -34,3 +37,110 @@ func TestInsertRopeRope(t *testing.T) {
 		t.Fatal(string(rr.Bytes()))
 	}
 }
+
+func TestRopeRopeOutOfRange(t *testing.T) {
+	var rows []Rope
+	for _, s := range []string{"foo", "bar", "baz"} {
+		rows = append(rows, *NewFromBytes([]byte(s)))
+	}
+	rr := NewFromRope(rows)
+	if _, err := rr.TryIndex(3); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if row, err := rr.TryIndex(1); err != nil || string(row.Bytes()) != "bar" {
+		t.Fatal()
+	}
+	if _, _, err := rr.TrySplit(-1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := rr.TrySub(2, 2); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := rr.TryDelete(0, -1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := rr.TryInsert(4, nil); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	func() {
+		defer func() {
+			if recover() != ErrOutOfRange {
+				t.Fatal()
+			}
+		}()
+		rr.Index(-1)
+	}()
+	// unchecked variants clamp
+	if r1, r2 := rr.Split(-3); r1.Len() != 0 || r2.Len() != 3 {
+		t.Fatal()
+	}
+	if len(rr.Sub(1, -1)) != 0 || len(rr.Sub(-1, 2)) != 1 || len(rr.Sub(2, 100)) != 1 {
+		t.Fatal()
+	}
+	if rr.Delete(-1, 2).Len() != 2 || rr.Delete(2, 100).Len() != 2 || string(rr.Insert(100, rows[:1]).Bytes()) != "foobarbazfoo" {
+		t.Fatal()
+	}
+	if _, err := rr.TrySub(1, math.MaxInt); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if len(rr.Sub(1, math.MaxInt)) != 2 || rr.Delete(math.MaxInt, math.MaxInt).Len() != 3 {
+		t.Fatal()
+	}
+	// iteration offsets clamp
+	for _, offset := range []int{-5, 0, 3, 100, math.MaxInt} {
+		n := 0
+		rr.Iter(offset, func(rows []Rope) bool {
+			n += len(rows)
+			return true
+		})
+		if n != 3-min(max(offset, 0), 3) {
+			t.Fatal(offset)
+		}
+		n = 0
+		rr.IterBackward(offset, func(rows []Rope) bool {
+			n += len(rows)
+			return true
+		})
+		if n != min(max(offset, 0), 3) {
+			t.Fatal(offset)
+		}
+	}
+
+	var runeRows []RuneRope
+	for _, s := range []string{"foo", "bar", "baz"} {
+		runeRows = append(runeRows, *NewFromRunes([]rune(s)))
+	}
+	rrr := NewFromRuneRope(runeRows)
+	if _, err := rrr.TryIndex(3); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if _, err := rrr.TrySub(-1, 1); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if r1, r2 := rrr.Split(100); r1.Len() != 3 || r2.Len() != 0 {
+		t.Fatal()
+	}
+	if len(rrr.Sub(2, -1)) != 0 || rrr.Delete(1, 100).Len() != 1 {
+		t.Fatal()
+	}
+	if _, err := rrr.TryDelete(1, math.MaxInt); err != ErrOutOfRange {
+		t.Fatal()
+	}
+	if len(rrr.Sub(1, math.MaxInt)) != 2 || rrr.Delete(1, math.MaxInt).Len() != 1 {
+		t.Fatal()
+	}
+	for _, offset := range []int{-5, 2, math.MaxInt} {
+		n := 0
+		rrr.Iter(offset, func(rows []RuneRope) bool {
+			n += len(rows)
+			return true
+		})
+		rrr.IterBackward(offset, func(rows []RuneRope) bool {
+			n += len(rows)
+			return true
+		})
+		if n != 3 {
+			t.Fatal(offset)
+		}
+	}
+}
@@ -1,54 +1,61 @@
 package rope
 
-import "math"
-
 type RopeRuneRope struct {
-	height   int
-	weight   int
-	left     *RopeRuneRope
-	right    *RopeRuneRope
-	content  []RuneRope
-	balanced bool
+	height  int
+	weight  int
+	left    *RopeRuneRope
+	right   *RopeRuneRope
+	content []RuneRope
+	cfg     *Config[RuneRope]
 }
 
-var MaxLengthPerNodeRuneRope = 512
-
 // NewFromBytes genearte new rope from bytes
 func NewFromRuneRope(bs []RuneRope) (ret *RopeRuneRope) {
+	return NewFromRuneRopeWithConfig(bs, nil)
+}
+
+// NewFromRuneRopeWithConfig generates a new rope using cfg, nil for the default configuration
+func NewFromRuneRopeWithConfig(bs []RuneRope, cfg *Config[RuneRope]) *RopeRuneRope {
+	return newFromRuneRope(bs, cfg.normalize(defaultRuneRopeConfig))
+}
+
+// newFromRuneRope generates a new rope using cfg, which is normalized
+func newFromRuneRope(bs []RuneRope, cfg *Config[RuneRope]) (ret *RopeRuneRope) {
+	maxLength := cfg.MaxLengthPerNode
 	if len(bs) == 0 {
 		return nil
 	}
 	slots := make([]*RopeRuneRope, 32)
 	var slotIndex int
 	var r *RopeRuneRope
-	for blockIndex := 0; blockIndex < len(bs)/MaxLengthPerNodeRope; blockIndex++ {
+	for blockIndex := 0; blockIndex < len(bs)/maxLength; blockIndex++ {
 		r = &RopeRuneRope{
-			height:   1,
-			weight:   MaxLengthPerNodeRope,
-			content:  bs[blockIndex*MaxLengthPerNodeRope : (blockIndex+1)*MaxLengthPerNodeRope],
-			balanced: true,
+			height:  1,
+			weight:  maxLength,
+			content: bs[blockIndex*maxLength : (blockIndex+1)*maxLength],
+			cfg:     cfg,
 		}
 		slotIndex = 0
 		for slots[slotIndex] != nil {
 			r = &RopeRuneRope{
-				height:   slotIndex + 2,
-				weight:   (1 << uint(slotIndex)) * MaxLengthPerNodeRope,
-				left:     slots[slotIndex],
-				right:    r,
-				balanced: true,
+				height: slotIndex + 2,
+				weight: (1 << uint(slotIndex)) * maxLength,
+				left:   slots[slotIndex],
+				right:  r,
+				cfg:    cfg,
 			}
 			slots[slotIndex] = nil
 			slotIndex++
 		}
 		slots[slotIndex] = r
 	}
-	tailStart := len(bs) / MaxLengthPerNodeRope * MaxLengthPerNodeRope
+	tailStart := len(bs) / maxLength * maxLength
 	if tailStart < len(bs) {
 		ret = &RopeRuneRope{
-			height:   1,
-			weight:   len(bs) - tailStart,
-			content:  bs[tailStart:],
-			balanced: false,
+			height:  1,
+			weight:  len(bs) - tailStart,
+			content: bs[tailStart:],
+			cfg:     cfg,
 		}
 	}
 	for _, c := range slots {
@@ -63,18 +70,62 @@ func NewFromRuneRope(bs []RuneRope) (ret *RopeRuneRope) {
 	return
 }
 
-// Index returns rope at index
+// Index returns the row at index, it panics with ErrOutOfRange if row is not in [0, Len)
 func (r *RopeRuneRope) Index(row int) RuneRope {
+	ret, err := r.TryIndex(row)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// TryIndex returns the row at index, or ErrOutOfRange
+func (r *RopeRuneRope) TryIndex(row int) (RuneRope, error) {
+	if row < 0 || row >= r.Len() {
+		return RuneRope{}, ErrOutOfRange
+	}
+	return r.index(row), nil
+}
+
+func (r *RopeRuneRope) index(row int) RuneRope {
 	if row >= r.weight {
-		return r.right.Index(row - r.weight)
+		return r.right.index(row - r.weight)
 	}
 	if r.left != nil { // non leaf
-		return r.left.Index(row)
+		return r.left.index(row)
 	}
 	// leaf
 	return r.content[row]
 }
 
+// clampRange returns the part of the range of l rows at n inside the rope
+func (r *RopeRuneRope) clampRange(n, l int) (int, int) {
+	length := r.Len()
+	l = max(l, 0)
+	if n < 0 {
+		l = max(0, l+n)
+		n = 0
+	}
+	n = min(n, length)
+	return n, min(l, length-n)
+}
+
+// checkRange returns ErrOutOfRange if the range of l rows at n is not inside the rope
+func (r *RopeRuneRope) checkRange(n, l int) error {
+	if n < 0 || l < 0 || n > r.Len() || l > r.Len()-n {
+		return ErrOutOfRange
+	}
+	return nil
+}
+
+// config returns the configuration of the rope
+func (r *RopeRuneRope) config() *Config[RuneRope] {
+	if r == nil || r.cfg == nil {
+		return defaultRuneRopeConfig
+	}
+	return r.cfg
+}
+
 // Len returns the length of the rope
 func (r *RopeRuneRope) Len() int {
 	if r == nil {
@@ -108,94 +159,99 @@ func (r *RopeRuneRope) Bytes() []byte {
 	return ret
 }
 
-// Concat concatinates two RopeRuneRopes
-func (r *RopeRuneRope) Concat(r2 *RopeRuneRope) (ret *RopeRuneRope) {
-	ret = &RopeRuneRope{
-		weight: r.Len(),
-		left:   r,
-		right:  r2,
+// Concat returns r followed by r2. Ropes are AVL trees, the lower one is joined
+// along the spine of the higher one with rotations, in O(log n).
+func (r *RopeRuneRope) Concat(r2 *RopeRuneRope) *RopeRuneRope {
+	return r.concat(r2, r.Len()).mustValidate()
+}
+
+// concat is Concat with the length l of r known
+func (r *RopeRuneRope) concat(r2 *RopeRuneRope, l int) *RopeRuneRope {
+	if r2 == nil || r2.height == 0 {
+		if r == nil {
+			return r2
+		}
+		return r
 	}
-	if ret.left != nil {
-		ret.height = ret.left.height
+	if r == nil || r.height == 0 {
+		return r2
 	}
-	if ret.right != nil && ret.right.height > ret.height {
-		ret.height = ret.right.height
+	return r.join(r2, l)
+}
+
+// join joins two non empty ropes, l being the length of r
+func (r *RopeRuneRope) join(r2 *RopeRuneRope, l int) *RopeRuneRope {
+	switch {
+	case r.height > r2.height+1:
+		return r.newNode(r.left, r.right.join(r2, l-r.weight), r.weight).balance()
+	case r2.height > r.height+1:
+		return r.newNode(r.join(r2.left, l), r2.right, l+r2.weight).balance()
 	}
-	if ret.left != nil && ret.left.balanced &&
-		ret.right != nil && ret.right.balanced &&
-		ret.left.height == ret.right.height {
-		ret.balanced = true
+	return r.newNode(r, r2, l)
+}
+
+func (r *RopeRuneRope) newNode(left, right *RopeRuneRope, weight int) *RopeRuneRope {
+	height := left.height
+	if right.height > height {
+		height = right.height
 	}
-	ret.height++
-	// check and rebalance
-	if !ret.balanced {
-		l := int((math.Ceil(math.Log2(float64((ret.Len()/MaxLengthPerNodeRope)+1))) + 1) * 1.5)
-		if ret.height > l {
-			ret = ret.rebalance()
-		}
+	return &RopeRuneRope{
+		height: height + 1,
+		weight: weight,
+		left:   left,
+		right:  right,
+		cfg:    r.config(),
 	}
-	return
 }
 
-func (r *RopeRuneRope) rebalance() (ret *RopeRuneRope) {
-	var currentBytes []RuneRope
-	slots := make([]*RopeRuneRope, 32)
-	r.iterNodes(func(node *RopeRuneRope) bool {
-		var balancedNode *RopeRuneRope
-		iterSubNodes := true
-		if len(currentBytes) == 0 && node.balanced { // balanced, insert to slots
-			balancedNode = node
-			iterSubNodes = false
-		} else { // collect bytes
-			currentBytes = append(currentBytes, node.content...)
-			if len(currentBytes) >= MaxLengthPerNodeRope { // a full leaf
-				balancedNode = &RopeRuneRope{
-					height:   1,
-					weight:   MaxLengthPerNodeRope,
-					balanced: true,
-					content:  currentBytes[:MaxLengthPerNodeRope],
-				}
-				currentBytes = currentBytes[MaxLengthPerNodeRope:]
-			}
+// balance restores the height invariant of a node whose children heights differ by at most 2
+func (r *RopeRuneRope) balance() *RopeRuneRope {
+	switch {
+	case r.left.height > r.right.height+1:
+		left := r.left
+		if left.right.height > left.left.height {
+			left = left.rotateLeft()
 		}
-		if balancedNode != nil {
-			slotIndex := balancedNode.height - 1
-			for slots[slotIndex] != nil {
-				balancedNode = &RopeRuneRope{
-					height:   balancedNode.height + 1,
-					weight:   slots[slotIndex].Len(),
-					left:     slots[slotIndex],
-					right:    balancedNode,
-					balanced: true,
-				}
-				slots[slotIndex] = nil
-				slotIndex++
-			}
-			slots[slotIndex] = balancedNode
-		}
-		return iterSubNodes
-	})
-	if len(currentBytes) > 0 {
-		ret = &RopeRuneRope{
-			height:   1,
-			weight:   len(currentBytes),
-			balanced: false,
-			content:  currentBytes,
+		return r.newNode(left, r.right, r.weight).rotateRight()
+	case r.right.height > r.left.height+1:
+		right := r.right
+		if right.left.height > right.right.height {
+			right = right.rotateRight()
 		}
+		return r.newNode(r.left, right, r.weight).rotateLeft()
 	}
-	for _, c := range slots {
-		if c != nil {
-			if ret == nil {
-				ret = c
-			} else {
-				ret = c.Concat(ret)
-			}
-		}
-	}
-	return
+	return r
+}
+
+// rotateLeft turns (a, (b, c)) into ((a, b), c)
+func (r *RopeRuneRope) rotateLeft() *RopeRuneRope {
+	right := r.right
+	return r.newNode(r.newNode(r.left, right.left, r.weight), right.right, r.weight+right.weight)
+}
+
+// rotateRight turns ((a, b), c) into (a, (b, c))
+func (r *RopeRuneRope) rotateRight() *RopeRuneRope {
+	left := r.left
+	return r.newNode(left.left, r.newNode(left.right, r.right, r.weight-left.weight), left.weight)
 }
 
+// Split splits the rope at row n, clamped to [0, Len]
 func (r *RopeRuneRope) Split(n int) (out1, out2 *RopeRuneRope) {
+	n, _ = r.clampRange(n, 0)
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate()
+}
+
+// TrySplit splits the rope at row n, or returns ErrOutOfRange
+func (r *RopeRuneRope) TrySplit(n int) (out1, out2 *RopeRuneRope, err error) {
+	if err = r.checkRange(n, 0); err != nil {
+		return
+	}
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate(), nil
+}
+
+func (r *RopeRuneRope) split(n int) (out1, out2 *RopeRuneRope) {
 	if r == nil {
 		return
 	}
@@ -203,34 +259,62 @@ func (r *RopeRuneRope) Split(n int) (out1, out2 *RopeRuneRope) {
 		if n > len(r.content) { // offset overflow
 			n = len(r.content)
 		}
-		out1 = NewFromRuneRope(r.content[:n])
-		out2 = NewFromRuneRope(r.content[n:])
+		out1 = newFromRuneRope(r.content[:n], r.config())
+		out2 = newFromRuneRope(r.content[n:], r.config())
 	} else { // non leaf
 		var r1 *RopeRuneRope
 		if n >= r.weight { // at right subtree
-			r1, out2 = r.right.Split(n - r.weight)
-			out1 = r.left.Concat(r1)
+			r1, out2 = r.right.split(n - r.weight)
+			out1 = r.left.concat(r1, r.weight)
 		} else { // at left subtree
-			out1, r1 = r.left.Split(n)
-			out2 = r1.Concat(r.right)
+			out1, r1 = r.left.split(n)
+			out2 = r1.concat(r.right, r.weight-n)
 		}
 	}
 	return
 }
 
+// Insert inserts bs at row n, clamped to [0, Len]
 func (r *RopeRuneRope) Insert(n int, bs []RuneRope) *RopeRuneRope {
 	r1, r2 := r.Split(n)
-	return r1.Concat(NewFromRuneRope(bs)).Concat(r2)
+	return r1.Concat(newFromRuneRope(bs, r.config())).Concat(r2).mustValidate()
+}
+
+// TryInsert inserts bs at row n, or returns ErrOutOfRange
+func (r *RopeRuneRope) TryInsert(n int, bs []RuneRope) (*RopeRuneRope, error) {
+	if err := r.checkRange(n, 0); err != nil {
+		return nil, err
+	}
+	return r.Insert(n, bs), nil
 }
 
+// Delete deletes l rows at row n, the part of the range outside of the rope is ignored
 func (r *RopeRuneRope) Delete(n, l int) *RopeRuneRope {
-	r1, r2 := r.Split(n)
-	_, r2 = r2.Split(l)
-	return r1.Concat(r2)
+	n, l = r.clampRange(n, l)
+	r1, r2 := r.split(n)
+	_, r2 = r2.split(l)
+	return r1.Concat(r2).mustValidate()
+}
+
+// TryDelete deletes l rows at row n, or returns ErrOutOfRange
+func (r *RopeRuneRope) TryDelete(n, l int) (*RopeRuneRope, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Delete(n, l), nil
+}
+
+// TrySub returns l rows at row n, or ErrOutOfRange
+func (r *RopeRuneRope) TrySub(n, l int) ([]RuneRope, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Sub(n, l), nil
 }
 
-// Sub returns a substring of the rope
+// Sub returns l rows at row n, the part of the range outside of the rope is ignored
 func (r *RopeRuneRope) Sub(n, l int) []RuneRope {
+	n, l = r.clampRange(n, l)
 	ret := make([]RuneRope, l)
 	i := 0
 	r.Iter(n, func(bs []RuneRope) bool {
@@ -252,6 +336,7 @@ func (r *RopeRuneRope) Iter(offset int, fn func([]RuneRope) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
+		offset = max(offset, 0)
 		if offset < len(r.content) {
 			if !fn(r.content[offset:]) {
 				return false
@@ -279,7 +364,7 @@ func (r *RopeRuneRope) IterBackward(offset int, fn func([]RuneRope) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
-		content := r.content[:offset]
+		content := r.content[:max(0, min(offset, len(r.content)))]
 		if len(content) == 0 {
 			return true
 		}
@@ -1,60 +1,67 @@
 package rope
 
-import "math"
-
 type RuneRope struct {
-	height   int
-	weight   int
-	left     *RuneRope
-	right    *RuneRope
-	content  []rune
-	balanced bool
+	height  int
+	weight  int
+	left    *RuneRope
+	right   *RuneRope
+	content []rune
+	cfg     *Config[rune]
 }
 
-var MaxLengthPerNodeRune = 128
-
 // NewFromrunes genearte new Runerope from runes
 func NewFromRunes(bs []rune) (ret *RuneRope) {
+	return NewFromRunesWithConfig(bs, nil)
+}
+
+// NewFromRunesWithConfig generates a new rope using cfg, nil for the default configuration
+func NewFromRunesWithConfig(bs []rune, cfg *Config[rune]) *RuneRope {
+	return newFromRunes(bs, cfg.normalize(defaultRuneConfig))
+}
+
+// newFromRunes generates a new rope using cfg, which is normalized
+func newFromRunes(bs []rune, cfg *Config[rune]) (ret *RuneRope) {
+	maxLength := cfg.MaxLengthPerNode
 	if len(bs) == 0 {
 		ret = &RuneRope{
-			height:   0,
-			weight:   0,
-			content:  bs,
-			balanced: false,
+			height:  0,
+			weight:  0,
+			content: bs,
+			cfg:     cfg,
 		}
 		return
 	}
 	slots := make([]*RuneRope, 32)
 	var slotIndex int
 	var r *RuneRope
-	for blockIndex := 0; blockIndex < len(bs)/MaxLengthPerNodeRune; blockIndex++ {
+	for blockIndex := 0; blockIndex < len(bs)/maxLength; blockIndex++ {
 		r = &RuneRope{
-			height:   1,
-			weight:   MaxLengthPerNodeRune,
-			content:  bs[blockIndex*MaxLengthPerNodeRune : (blockIndex+1)*MaxLengthPerNodeRune],
-			balanced: true,
+			height:  1,
+			weight:  maxLength,
+			content: bs[blockIndex*maxLength : (blockIndex+1)*maxLength],
+			cfg:     cfg,
 		}
 		slotIndex = 0
 		for slots[slotIndex] != nil {
 			r = &RuneRope{
-				height:   slotIndex + 2,
-				weight:   (1 << uint(slotIndex)) * MaxLengthPerNodeRune,
-				left:     slots[slotIndex],
-				right:    r,
-				balanced: true,
+				height: slotIndex + 2,
+				weight: (1 << uint(slotIndex)) * maxLength,
+				left:   slots[slotIndex],
+				right:  r,
+				cfg:    cfg,
 			}
 			slots[slotIndex] = nil
 			slotIndex++
 		}
 		slots[slotIndex] = r
 	}
-	tailStart := len(bs) / MaxLengthPerNodeRune * MaxLengthPerNodeRune
+	tailStart := len(bs) / maxLength * maxLength
 	if tailStart < len(bs) {
 		ret = &RuneRope{
-			height:   1,
-			weight:   len(bs) - tailStart,
-			content:  bs[tailStart:],
-			balanced: false,
+			height:  1,
+			weight:  len(bs) - tailStart,
+			content: bs[tailStart:],
+			cfg:     cfg,
 		}
 	}
 	for _, c := range slots {
@@ -69,18 +76,42 @@ func NewFromRunes(bs []rune) (ret *RuneRope) {
 	return
 }
 
-// Index returns byte at index
+// Index returns rune at index, it panics with ErrOutOfRange if i is not in [0, Len)
 func (r *RuneRope) Index(i int) rune {
+	c, err := r.TryIndex(i)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// TryIndex returns the rune at index i, or ErrOutOfRange
+func (r *RuneRope) TryIndex(i int) (rune, error) {
+	if i < 0 || i >= r.Len() {
+		return 0, ErrOutOfRange
+	}
+	return r.index(i), nil
+}
+
+func (r *RuneRope) index(i int) rune {
 	if i >= r.weight {
-		return r.right.Index(i - r.weight)
+		return r.right.index(i - r.weight)
 	}
 	if r.left != nil { // non leaf
-		return r.left.Index(i)
+		return r.left.index(i)
 	}
 	// leaf
 	return r.content[i]
 }
 
+// config returns the configuration of the rope
+func (r *RuneRope) config() *Config[rune] {
+	if r == nil || r.cfg == nil {
+		return defaultRuneConfig
+	}
+	return r.cfg
+}
+
 // Len returns the length of the Runerope
 func (r *RuneRope) Len() int {
 	if r == nil {
@@ -101,93 +132,119 @@ func (r *RuneRope) Runes() []rune {
 	return ret
 }
 
-func (r *RuneRope) Concat(r2 *RuneRope) (ret *RuneRope) {
-	ret = &RuneRope{
-		weight: r.Len(),
-		left:   r,
-		right:  r2,
+// Concat returns r followed by r2. Ropes are AVL trees, the lower one is joined
+// along the spine of the higher one with rotations, in O(log n).
+func (r *RuneRope) Concat(r2 *RuneRope) *RuneRope {
+	return r.concat(r2, r.Len()).mustValidate()
+}
+
+// concat is Concat with the length l of r known
+func (r *RuneRope) concat(r2 *RuneRope, l int) *RuneRope {
+	if r2 == nil || r2.height == 0 {
+		if r == nil {
+			return r2
+		}
+		return r
 	}
-	if ret.left != nil {
-		ret.height = ret.left.height
+	if r == nil || r.height == 0 {
+		return r2
 	}
-	if ret.right != nil && ret.right.height > ret.height {
-		ret.height = ret.right.height
+	return r.join(r2, l)
+}
+
+// join joins two non empty ropes, l being the length of r
+func (r *RuneRope) join(r2 *RuneRope, l int) *RuneRope {
+	switch {
+	case r.height > r2.height+1:
+		return r.newNode(r.left, r.right.join(r2, l-r.weight), r.weight).balance()
+	case r2.height > r.height+1:
+		return r.newNode(r.join(r2.left, l), r2.right, l+r2.weight).balance()
 	}
-	if ret.left != nil && ret.left.balanced &&
-		ret.right != nil && ret.right.balanced &&
-		ret.left.height == ret.right.height {
-		ret.balanced = true
+	return r.newNode(r, r2, l)
+}
+
+func (r *RuneRope) newNode(left, right *RuneRope, weight int) *RuneRope {
+	height := left.height
+	if right.height > height {
+		height = right.height
 	}
-	ret.height++
-	// check and rebalance
-	if !ret.balanced {
-		l := int((math.Ceil(math.Log2(float64((ret.Len()/MaxLengthPerNodeRune)+1))) + 1) * 1.5)
-		if ret.height > l {
-			ret = ret.rebalance()
-		}
+	return &RuneRope{
+		height: height + 1,
+		weight: weight,
+		left:   left,
+		right:  right,
+		cfg:    r.config(),
 	}
-	return
 }
 
-func (r *RuneRope) rebalance() (ret *RuneRope) {
-	var currentrunes []rune
-	slots := make([]*RuneRope, 32)
-	r.iterNodes(func(node *RuneRope) bool {
-		var balancedNode *RuneRope
-		iterSubNodes := true
-		if len(currentrunes) == 0 && node.balanced { // balanced, insert to slots
-			balancedNode = node
-			iterSubNodes = false
-		} else { // collect runes
-			currentrunes = append(currentrunes, node.content...)
-			if len(currentrunes) >= MaxLengthPerNodeRune { // a full leaf
-				balancedNode = &RuneRope{
-					height:   1,
-					weight:   MaxLengthPerNodeRune,
-					balanced: true,
-					content:  currentrunes[:MaxLengthPerNodeRune],
-				}
-				currentrunes = currentrunes[MaxLengthPerNodeRune:]
-			}
-		}
-		if balancedNode != nil {
-			slotIndex := balancedNode.height - 1
-			for slots[slotIndex] != nil {
-				balancedNode = &RuneRope{
-					height:   balancedNode.height + 1,
-					weight:   slots[slotIndex].Len(),
-					left:     slots[slotIndex],
-					right:    balancedNode,
-					balanced: true,
-				}
-				slots[slotIndex] = nil
-				slotIndex++
-			}
-			slots[slotIndex] = balancedNode
+// balance restores the height invariant of a node whose children heights differ by at most 2
+func (r *RuneRope) balance() *RuneRope {
+	switch {
+	case r.left.height > r.right.height+1:
+		left := r.left
+		if left.right.height > left.left.height {
+			left = left.rotateLeft()
 		}
-		return iterSubNodes
-	})
-	if len(currentrunes) > 0 {
-		ret = &RuneRope{
-			height:   1,
-			weight:   len(currentrunes),
-			balanced: false,
-			content:  currentrunes,
+		return r.newNode(left, r.right, r.weight).rotateRight()
+	case r.right.height > r.left.height+1:
+		right := r.right
+		if right.left.height > right.right.height {
+			right = right.rotateRight()
 		}
+		return r.newNode(r.left, right, r.weight).rotateLeft()
 	}
-	for _, c := range slots {
-		if c != nil {
-			if ret == nil {
-				ret = c
-			} else {
-				ret = c.Concat(ret)
-			}
-		}
+	return r
+}
+
+// rotateLeft turns (a, (b, c)) into ((a, b), c)
+func (r *RuneRope) rotateLeft() *RuneRope {
+	right := r.right
+	return r.newNode(r.newNode(r.left, right.left, r.weight), right.right, r.weight+right.weight)
+}
+
+// rotateRight turns ((a, b), c) into (a, (b, c))
+func (r *RuneRope) rotateRight() *RuneRope {
+	left := r.left
+	return r.newNode(left.left, r.newNode(left.right, r.right, r.weight-left.weight), left.weight)
+}
+
+// clampRange returns the part of the range of l runes at n inside the rope
+func (r *RuneRope) clampRange(n, l int) (int, int) {
+	length := r.Len()
+	l = max(l, 0)
+	if n < 0 {
+		l = max(0, l+n)
+		n = 0
 	}
-	return
+	n = min(n, length)
+	return n, min(l, length-n)
+}
+
+// checkRange returns ErrOutOfRange if the range of l runes at n is not inside the rope
+func (r *RuneRope) checkRange(n, l int) error {
+	if n < 0 || l < 0 || n > r.Len() || l > r.Len()-n {
+		return ErrOutOfRange
+	}
+	return nil
 }
 
+// Split splits the rope at offset n, clamped to [0, Len]
 func (r *RuneRope) Split(n int) (out1, out2 *RuneRope) {
+	n, _ = r.clampRange(n, 0)
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate()
+}
+
+// TrySplit splits the rope at offset n, or returns ErrOutOfRange
+func (r *RuneRope) TrySplit(n int) (out1, out2 *RuneRope, err error) {
+	if err = r.checkRange(n, 0); err != nil {
+		return
+	}
+	out1, out2 = r.split(n)
+	return out1.mustValidate(), out2.mustValidate(), nil
+}
+
+func (r *RuneRope) split(n int) (out1, out2 *RuneRope) {
 	if r == nil {
 		return
 	}
@@ -195,34 +252,62 @@ func (r *RuneRope) Split(n int) (out1, out2 *RuneRope) {
 		if n > len(r.content) { // offset overflow
 			n = len(r.content)
 		}
-		out1 = NewFromRunes(r.content[:n])
-		out2 = NewFromRunes(r.content[n:])
+		out1 = newFromRunes(r.content[:n], r.config())
+		out2 = newFromRunes(r.content[n:], r.config())
 	} else { // non leaf
 		var r1 *RuneRope
 		if n >= r.weight { // at right subtree
-			r1, out2 = r.right.Split(n - r.weight)
-			out1 = r.left.Concat(r1)
+			r1, out2 = r.right.split(n - r.weight)
+			out1 = r.left.concat(r1, r.weight)
 		} else { // at left subtree
-			out1, r1 = r.left.Split(n)
-			out2 = r1.Concat(r.right)
+			out1, r1 = r.left.split(n)
+			out2 = r1.concat(r.right, r.weight-n)
 		}
 	}
 	return
 }
 
+// Insert inserts bs at offset n, clamped to [0, Len]
 func (r *RuneRope) Insert(n int, bs []rune) *RuneRope {
 	r1, r2 := r.Split(n)
-	return r1.Concat(NewFromRunes(bs)).Concat(r2)
+	return r1.Concat(newFromRunes(bs, r.config())).Concat(r2).mustValidate()
+}
+
+// TryInsert inserts bs at offset n, or returns ErrOutOfRange
+func (r *RuneRope) TryInsert(n int, bs []rune) (*RuneRope, error) {
+	if err := r.checkRange(n, 0); err != nil {
+		return nil, err
+	}
+	return r.Insert(n, bs), nil
 }
 
+// Delete deletes l runes at offset n, the part of the range outside of the rope is ignored
 func (r *RuneRope) Delete(n, l int) *RuneRope {
-	r1, r2 := r.Split(n)
-	_, r2 = r2.Split(l)
-	return r1.Concat(r2)
+	n, l = r.clampRange(n, l)
+	r1, r2 := r.split(n)
+	_, r2 = r2.split(l)
+	return r1.Concat(r2).mustValidate()
+}
+
+// TryDelete deletes l runes at offset n, or returns ErrOutOfRange
+func (r *RuneRope) TryDelete(n, l int) (*RuneRope, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Delete(n, l), nil
+}
+
+// TrySub returns l runes at offset n, or ErrOutOfRange
+func (r *RuneRope) TrySub(n, l int) ([]rune, error) {
+	if err := r.checkRange(n, l); err != nil {
+		return nil, err
+	}
+	return r.Sub(n, l), nil
 }
 
-// Sub returns a substring of the Runerope
+// Sub returns a substring of the Runerope, the part of the range outside of the rope is ignored
 func (r *RuneRope) Sub(n, l int) []rune {
+	n, l = r.clampRange(n, l)
 	ret := make([]rune, l)
 	i := 0
 	r.Iter(n, func(bs []rune) bool {
@@ -245,6 +330,7 @@ func (r *RuneRope) Iter(offset int, fn func([]rune) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
+		offset = max(offset, 0)
 		if offset < len(r.content) {
 			if !fn(r.content[offset:]) {
 				return false
@@ -272,7 +358,7 @@ func (r *RuneRope) IterBackward(offset int, fn func([]rune) bool) bool {
 		return true
 	}
 	if len(r.content) > 0 { // leaf
-		content := r.content[:offset]
+		content := r.content[:max(0, min(offset, len(r.content)))]
 		if len(content) == 0 {
 			return true
 		}
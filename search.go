@@ -0,0 +1,140 @@
+package rope
+
+import (
+	"bytes"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// scanMatches calls fn with the offsets of all matches of sep starting in [start, end), overlapping ones included.
+// Matches may extend past end.
+func (r *Rope) scanMatches(start, end int, sep []byte, fn func(int) bool) {
+	var carry []byte // the bytes before pos that may start a match
+	pos := start
+	r.Iter(start, func(bs []byte) bool {
+		if len(carry) > 0 { // matches crossing the chunk boundary
+			joined := append(carry[:len(carry):len(carry)], bs[:min(len(bs), len(sep)-1)]...)
+			for i := 0; i < len(carry); i++ {
+				if bytes.HasPrefix(joined[i:], sep) {
+					if pos-len(carry)+i >= end {
+						return false
+					}
+					if !fn(pos - len(carry) + i) {
+						return false
+					}
+				}
+			}
+		}
+		for i := 0; ; {
+			j := bytes.Index(bs[i:], sep)
+			if j < 0 {
+				break
+			}
+			if pos+i+j >= end {
+				return false
+			}
+			if !fn(pos + i + j) {
+				return false
+			}
+			i += j + 1
+		}
+		if len(bs) >= len(sep)-1 {
+			carry = append(carry[:0], bs[len(bs)-(len(sep)-1):]...)
+		} else {
+			carry = append(carry, bs...)
+			carry = carry[max(0, len(carry)-(len(sep)-1)):]
+		}
+		pos += len(bs)
+		return pos-len(carry) < end
+	})
+}
+
+// selfOverlapping reports whether two matches of sep can overlap
+func selfOverlapping(sep []byte) bool {
+	for i := 1; i < len(sep); i++ {
+		if bytes.HasPrefix(sep, sep[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count counts the number of non-overlapping instances of sep, like bytes.Count
+func (r *Rope) Count(sep []byte) (n int) {
+	if len(sep) == 0 {
+		n = 1
+		r.chunks(func(bs []byte) bool {
+			n += utf8.RuneCount(bs)
+			return true
+		})
+		return
+	}
+	if r.parallel() && !selfOverlapping(sep) {
+		// matches can not overlap, so they are counted per piece independently
+		workers := r.config().Workers
+		bounds := r.pieces(workers * 4)
+		counts := make([]int, len(bounds)-1)
+		eachPiece(bounds, workers, func(i, start, end int) {
+			c := 0
+			r.scanMatches(start, end, sep, func(int) bool {
+				c++
+				return true
+			})
+			counts[i] = c
+		})
+		for _, c := range counts {
+			n += c
+		}
+		return
+	}
+	next := 0
+	r.scanMatches(0, r.Len(), sep, func(i int) bool {
+		if i >= next {
+			n++
+			next = i + len(sep)
+		}
+		return true
+	})
+	return
+}
+
+// IndexBytes returns the offset of the first instance of sep, or -1 if not present
+func (r *Rope) IndexBytes(sep []byte) (ret int) {
+	if len(sep) == 0 {
+		return 0
+	}
+	ret = -1
+	if r.parallel() {
+		workers := r.config().Workers
+		bounds := r.pieces(workers * 4)
+		firsts := make([]int, len(bounds)-1)
+		// pieces after the lowest one with a match stop early, scanning in blocks
+		var lowest atomic.Int64
+		lowest.Store(int64(len(firsts)))
+		block := parallelMinLeaves * r.config().MaxLengthPerNode
+		eachPiece(bounds, workers, func(i, start, end int) {
+			firsts[i] = -1
+			for s := start; s < end && int64(i) < lowest.Load(); s += block {
+				r.scanMatches(s, min(s+block, end), sep, func(j int) bool {
+					firsts[i] = j
+					return false
+				})
+				if firsts[i] >= 0 {
+					storeMin(&lowest, int64(i))
+					return
+				}
+			}
+		})
+		for _, i := range firsts {
+			if i >= 0 {
+				return i
+			}
+		}
+		return
+	}
+	r.scanMatches(0, r.Len(), sep, func(i int) bool {
+		ret = i
+		return false
+	})
+	return
+}
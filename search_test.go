@@ -0,0 +1,41 @@
+package rope
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	bs := bytes.Repeat([]byte("foo aaa bar aaaa\nbaz ab"), 300)
+	seps := []string{"", "a", "aa", "aaa", "foo", "o a", "bar aaaa\nbaz", "ab", "\n", "zzz", "aaaa\nbaz abfoo"}
+	for _, workers := range []int{0, 1, 2, 4} {
+		r := NewFromBytesWithConfig(bs, &Config[byte]{MaxLengthPerNode: 8, Workers: workers})
+		r = r.Insert(100, []byte("aa"))
+		r = r.Insert(3000, []byte("foo"))
+		expected := r.Bytes()
+		for _, sep := range seps {
+			if r.Count([]byte(sep)) != bytes.Count(expected, []byte(sep)) {
+				t.Fatalf("%d %q", workers, sep)
+			}
+			if r.IndexBytes([]byte(sep)) != bytes.Index(expected, []byte(sep)) {
+				t.Fatalf("%d %q", workers, sep)
+			}
+			for _, i := range []int{1, 1000, 5000} {
+				_, r2 := r.Split(i)
+				if r2.IndexBytes([]byte(sep)) != bytes.Index(expected[i:], []byte(sep)) {
+					t.Fatal()
+				}
+			}
+		}
+	}
+	r := NewFromBytes(nil)
+	if r.Count([]byte("a")) != 0 || r.Count(nil) != 1 || r.IndexBytes([]byte("a")) != -1 {
+		t.Fatal()
+	}
+	// runes straddling leaves are counted once
+	bs = bytes.Repeat([]byte("é"), 10)
+	r = NewFromBytesWithConfig(bs, &Config[byte]{MaxLengthPerNode: 3})
+	if r.Count(nil) != 11 || r.Insert(1, []byte("\xff")).Count(nil) != 13 {
+		t.Fatal()
+	}
+}
@@ -0,0 +1,189 @@
+package rope
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// segmentContext is the number of runes kept on each side of a candidate boundary.
+// Rules looking further than that treat the window edge as the text edge.
+const segmentContext = 32
+
+// propRange maps the runes from lo to hi to a property
+type propRange struct {
+	lo, hi rune
+	prop   int
+}
+
+// lookupProp returns the property of c in ranges, or 0 if no range holds it
+func lookupProp(ranges []propRange, c rune) int {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].hi >= c
+	})
+	if i < len(ranges) && ranges[i].lo <= c {
+		return ranges[i].prop
+	}
+	return 0
+}
+
+// segmentRule reports whether there is a boundary before props[i], 0 < i < len(props)
+type segmentRule func(props []int, i int) bool
+
+// iterRunesBackward iterates runes ending before offset in reverse order.
+// raw is only valid during the call.
+func (r *Rope) iterRunesBackward(offset int, fn func(c rune, raw []byte) bool) {
+	var buf [utf8.UTFMax]byte
+	n := 0 // buf[len(buf)-n:] holds pending bytes
+	stopped := false
+	emit := func() bool {
+		for n > 0 {
+			pending := buf[len(buf)-n:]
+			if n < len(buf) && !utf8.RuneStart(pending[0]) {
+				return true
+			}
+			c, l := utf8.DecodeLastRune(pending)
+			if !fn(c, pending[len(pending)-l:]) {
+				stopped = true
+				return false
+			}
+			copy(buf[len(buf)-n+l:], pending[:n-l])
+			n -= l
+		}
+		return true
+	}
+	r.IterBackward(offset, func(bs []byte) bool {
+		for _, b := range bs { // reversed bytes
+			n++
+			buf[len(buf)-n] = b
+			if !emit() {
+				return false
+			}
+		}
+		return true
+	})
+	for !stopped && n > 0 {
+		pending := buf[len(buf)-n:]
+		c, l := utf8.DecodeLastRune(pending)
+		if !fn(c, pending[len(pending)-l:]) {
+			return
+		}
+		copy(buf[len(buf)-n+l:], pending[:n-l])
+		n -= l
+	}
+}
+
+// iterBoundaries calls fn with each boundary after offset in increasing order
+func (r *Rope) iterBoundaries(offset int, prop func(rune) int, rule segmentRule, fn func(int) bool) {
+	var props, offs []int
+	r.iterRunesBackward(offset, func(c rune, raw []byte) bool {
+		props = append(props, prop(c))
+		offs = append(offs, 0)
+		return len(props) < segmentContext
+	})
+	for i, j := 0, len(props)-1; i < j; i, j = i+1, j-1 {
+		props[i], props[j] = props[j], props[i]
+	}
+	// the lookbehind offsets are never reported, nor is offset itself
+	k := len(props) + 1 // props[k] is the rune after the next candidate
+	end := offset
+	decide := func(eot bool) bool {
+		for k < len(props) && (eot || len(props)-k > segmentContext) {
+			if rule(props, k) {
+				if !fn(offs[k]) {
+					return false
+				}
+			}
+			k++
+		}
+		if k > 2*segmentContext {
+			drop := k - segmentContext
+			props = props[:copy(props, props[drop:])]
+			offs = offs[:copy(offs, offs[drop:])]
+			k -= drop
+		}
+		return true
+	}
+	stopped := false
+	r.iterRunes(offset, func(c rune, raw []byte) bool {
+		props = append(props, prop(c))
+		offs = append(offs, end)
+		end += len(raw)
+		if !decide(false) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if stopped || !decide(true) {
+		return
+	}
+	if end > offset {
+		fn(end)
+	}
+}
+
+// iterBoundariesBackward calls fn with each boundary before offset in decreasing order
+func (r *Rope) iterBoundariesBackward(offset int, prop func(rune) int, rule segmentRule, fn func(int) bool) {
+	var props, offs []int
+	start := offset
+	r.iterRunes(offset, func(c rune, raw []byte) bool {
+		props = append(props, prop(c))
+		offs = append(offs, start)
+		start += len(raw)
+		return len(props) < segmentContext
+	})
+	// props[k] is the rune after the next candidate, offset itself is never reported
+	k := -1
+	start = offset
+	decide := func(sot bool) bool {
+		for k > 0 && (sot || k > segmentContext) {
+			if rule(props, k) {
+				if !fn(offs[k]) {
+					return false
+				}
+			}
+			k--
+		}
+		if len(props)-k > 2*segmentContext {
+			props = props[:k+segmentContext]
+			offs = offs[:k+segmentContext]
+		}
+		return true
+	}
+	stopped := false
+	r.iterRunesBackward(offset, func(c rune, raw []byte) bool {
+		start -= len(raw)
+		props = append(props, 0)
+		copy(props[1:], props)
+		props[0] = prop(c)
+		offs = append(offs, 0)
+		copy(offs[1:], offs)
+		offs[0] = start
+		k++
+		if !decide(false) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if stopped || !decide(true) {
+		return
+	}
+	if offset > 0 {
+		fn(0)
+	}
+}
+
+// prevNonIgnored returns the index of the last element before i that is not ignored, or -1
+func prevNonIgnored(props []int, i int, ignored func(int) bool) int {
+	for i--; i >= 0 && ignored(props[i]); i-- {
+	}
+	return i
+}
+
+// nextNonIgnored returns the index of the first element after i that is not ignored, or len(props)
+func nextNonIgnored(props []int, i int, ignored func(int) bool) int {
+	for i++; i < len(props) && ignored(props[i]); i++ {
+	}
+	return i
+}
@@ -0,0 +1,166 @@
+package rope
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testSegments(t *testing.T, segments []string, forward, backward func(*Rope, int, func(int) bool)) {
+	s := strings.Join(segments, "")
+	r := NewFromBytes([]byte(s))
+	var boundaries []int
+	off := 0
+	for _, seg := range segments {
+		off += len(seg)
+		boundaries = append(boundaries, off)
+	}
+
+	var res []int
+	forward(r, 0, func(i int) bool {
+		res = append(res, i)
+		return true
+	})
+	if len(res) != len(boundaries) {
+		t.Fatalf("%q: %v %v", s, res, boundaries)
+	}
+	for i, b := range boundaries {
+		if res[i] != b {
+			t.Fatalf("%q: %v %v", s, res, boundaries)
+		}
+	}
+
+	res = res[:0]
+	backward(r, len(s), func(i int) bool {
+		res = append(res, i)
+		return true
+	})
+	if len(res) != len(boundaries) {
+		t.Fatalf("%q: %v %v", s, res, boundaries)
+	}
+	for i, b := range boundaries[:len(boundaries)-1] {
+		if res[len(res)-2-i] != b {
+			t.Fatalf("%q: %v %v", s, res, boundaries)
+		}
+	}
+	if res[len(res)-1] != 0 {
+		t.Fatal()
+	}
+
+	// from every offset
+	for o := range s {
+		next := -1
+		forward(r, o, func(i int) bool {
+			next = i
+			return false
+		})
+		prev := -1
+		backward(r, o, func(i int) bool {
+			prev = i
+			return false
+		})
+		expectedPrev := 0
+		for _, b := range boundaries {
+			if b > o {
+				if next != b {
+					t.Fatalf("%q: next %d: %d %d", s, o, next, b)
+				}
+				break
+			}
+			if b < o {
+				expectedPrev = b
+			}
+		}
+		if o == 0 {
+			expectedPrev = -1
+		}
+		if prev != expectedPrev {
+			t.Fatalf("%q: prev %d: %d %d", s, o, prev, expectedPrev)
+		}
+	}
+}
+
+func TestWordBoundaries(t *testing.T) {
+	testSegments(t, []string{
+		"The", " ", "quick", " ", "(", "“", "brown", "”", ")", " ", "fox", " ",
+		"can’t", " ", "jump", " ", "32.3", " ", "feet", ",", " ", "right", "?", "\r\n",
+		"été", "  ", "foo_bar", " ", "3,000.5", " ", "カタカナ", "漢", "字",
+		"🇺🇸", "🇫🇷", "👨‍👩‍👧", " ", "a.b", ".", " ", "\"", "quoted", "\"",
+	}, (*Rope).IterWordBoundaries, (*Rope).IterWordBoundariesBackward)
+
+	r := NewFromBytes([]byte("foo bar"))
+	if r.NextWordBoundary(0) != 3 || r.NextWordBoundary(3) != 4 || r.NextWordBoundary(7) != 7 {
+		t.Fatal()
+	}
+	if r.PrevWordBoundary(7) != 4 || r.PrevWordBoundary(2) != 0 || r.PrevWordBoundary(0) != 0 {
+		t.Fatal()
+	}
+}
+
+func TestSentenceBoundaries(t *testing.T) {
+	testSegments(t, []string{
+		"This is a test. ",
+		"And another one? ",
+		"Yes! ",
+		"“Quoted.” ",
+		"Mr. smith went to the U.S.A. today, okay. ",
+		"Pi is 3.14 about.\n",
+		"\n",
+		"Line one\r\n",
+		"End",
+	}, (*Rope).IterSentenceBoundaries, (*Rope).IterSentenceBoundariesBackward)
+}
+
+// testBreakFile checks segmentation against a file in the format of WordBreakTest.txt
+func testBreakFile(t *testing.T, path string, forward, backward func(*Rope, int, func(int) bool)) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var segments []string
+		var seg []byte
+		for _, field := range fields[1:] {
+			switch field {
+			case "÷":
+				segments = append(segments, string(seg))
+				seg = nil
+			case "×":
+			default:
+				c, err := strconv.ParseUint(field, 16, 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seg = append(seg, string(rune(c))...)
+			}
+		}
+		testSegments(t, segments, forward, backward)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWordBreakTest(t *testing.T) {
+	testBreakFile(t, "testdata/word_break_test.txt", (*Rope).IterWordBoundaries, (*Rope).IterWordBoundariesBackward)
+	// the UCD file can be dropped in testdata to run the official cases
+	if _, err := os.Stat("testdata/WordBreakTest.txt"); err == nil {
+		testBreakFile(t, "testdata/WordBreakTest.txt", (*Rope).IterWordBoundaries, (*Rope).IterWordBoundariesBackward)
+	}
+}
+
+func TestSentenceBreakTest(t *testing.T) {
+	testBreakFile(t, "testdata/sentence_break_test.txt", (*Rope).IterSentenceBoundaries, (*Rope).IterSentenceBoundariesBackward)
+	if _, err := os.Stat("testdata/SentenceBreakTest.txt"); err == nil {
+		testBreakFile(t, "testdata/SentenceBreakTest.txt", (*Rope).IterSentenceBoundaries, (*Rope).IterSentenceBoundariesBackward)
+	}
+}
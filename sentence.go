@@ -0,0 +1,104 @@
+package rope
+
+// Sentence segmentation per UAX #29
+
+const (
+	sbOther = iota
+	sbCR
+	sbLF
+	sbExtend
+	sbSep
+	sbFormat
+	sbSp
+	sbLower
+	sbUpper
+	sbOLetter
+	sbNumeric
+	sbATerm
+	sbSContinue
+	sbSTerm
+	sbClose
+)
+
+func sentenceProperty(c rune) int {
+	return lookupProp(sentenceBreakRanges, c)
+}
+
+func sentenceIgnored(p int) bool {
+	return p == sbExtend || p == sbFormat
+}
+
+func sentenceParaSep(p int) bool {
+	return p == sbSep || p == sbCR || p == sbLF
+}
+
+func sentenceBreak(props []int, i int) bool {
+	a, b := props[i-1], props[i]
+	switch {
+	case a == sbCR && b == sbLF: // SB3
+		return false
+	case sentenceParaSep(a): // SB4
+		return true
+	case sentenceIgnored(b): // SB5
+		return false
+	}
+	// SB5, ignore Extend and Format unless they follow a paragraph separator or the start of text
+	ia := prevNonIgnored(props, i, sentenceIgnored)
+	if ia < 0 || sentenceParaSep(props[ia]) {
+		ia = i - 1
+	}
+	a = props[ia]
+	before := -1
+	if j := prevNonIgnored(props, ia, sentenceIgnored); j >= 0 {
+		before = props[j]
+	}
+	switch {
+	case a == sbATerm && b == sbNumeric: // SB6
+		return false
+	case (before == sbUpper || before == sbLower) && a == sbATerm && b == sbUpper: // SB7
+		return false
+	}
+	// match SATerm Close* Sp* ending at a
+	j := ia
+	spaces := false
+	for j >= 0 && props[j] == sbSp {
+		spaces = true
+		j = prevNonIgnored(props, j, sentenceIgnored)
+	}
+	for j >= 0 && props[j] == sbClose {
+		j = prevNonIgnored(props, j, sentenceIgnored)
+	}
+	if j < 0 || props[j] != sbATerm && props[j] != sbSTerm {
+		return false // SB998
+	}
+	if props[j] == sbATerm { // SB8
+		for k := i; k < len(props); k = nextNonIgnored(props, k, sentenceIgnored) {
+			p := props[k]
+			if p == sbLower {
+				return false
+			}
+			if p == sbOLetter || p == sbUpper || sentenceParaSep(p) || p == sbATerm || p == sbSTerm {
+				break
+			}
+		}
+	}
+	switch {
+	case b == sbSContinue || b == sbATerm || b == sbSTerm: // SB8a
+		return false
+	case !spaces && (b == sbClose || b == sbSp || sentenceParaSep(b)): // SB9
+		return false
+	case b == sbSp || sentenceParaSep(b): // SB10
+		return false
+	}
+	return true // SB11
+}
+
+// IterSentenceBoundaries calls fn with each sentence boundary after offset in increasing order
+func (r *Rope) IterSentenceBoundaries(offset int, fn func(int) bool) {
+	r.iterBoundaries(offset, sentenceProperty, sentenceBreak, fn)
+}
+
+// IterSentenceBoundariesBackward calls fn with each sentence boundary before offset in decreasing order
+func (r *Rope) IterSentenceBoundariesBackward(offset int, fn func(int) bool) {
+	r.iterBoundariesBackward(offset, sentenceProperty, sentenceBreak, fn)
+}
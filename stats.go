@@ -0,0 +1,238 @@
+package rope
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"unsafe"
+)
+
+// Stats describes the shape of a rope
+type Stats struct {
+	// Nodes is the number of nodes, Leaves the number of non-empty leaves
+	Nodes, Leaves int
+	// Depth is the number of nodes on the longest path from the root to a leaf
+	Depth int
+	// Bytes is the length of the rope
+	Bytes int
+	// LeafSizes[i] is the number of leaves of length in [1<<i, 2<<i)
+	LeafSizes []int
+	// Fill is the ratio of Bytes to the capacity of the leaves
+	Fill float64
+	// SharedBytes is the number of bytes stored in nodes also reachable from the other versions
+	SharedBytes int
+}
+
+// Stats returns the statistics of the rope, others are the versions SharedBytes is computed against
+func (r *Rope) Stats(others ...*Rope) (s Stats) {
+	if r.Len() == 0 {
+		return
+	}
+	seen := make(map[*Rope]bool)
+	for _, o := range others {
+		o.iterNodes(func(n *Rope) bool {
+			if seen[n] {
+				return false
+			}
+			seen[n] = true
+			return true
+		})
+	}
+	var walk func(n *Rope, depth int, shared bool)
+	walk = func(n *Rope, depth int, shared bool) {
+		if n == nil {
+			return
+		}
+		s.Nodes++
+		shared = shared || seen[n]
+		if !n.isLeaf() {
+			walk(n.left, depth+1, shared)
+			walk(n.right, depth+1, shared)
+			return
+		}
+		s.Depth = max(s.Depth, depth)
+		if len(n.content) == 0 {
+			return
+		}
+		s.Leaves++
+		if shared {
+			s.SharedBytes += len(n.content)
+		}
+		bucket := bits.Len(uint(len(n.content))) - 1
+		for len(s.LeafSizes) <= bucket {
+			s.LeafSizes = append(s.LeafSizes, 0)
+		}
+		s.LeafSizes[bucket]++
+	}
+	walk(r, 1, false)
+	s.Bytes = r.Len()
+	s.Fill = float64(s.Bytes) / float64(s.Leaves*r.config().MaxLengthPerNode)
+	return
+}
+
+// WriteDot writes the tree in the Graphviz dot language, nodes shared by several parents are written once
+func (r *Rope) WriteDot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph rope {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	ids := make(map[*Rope]int)
+	var visit func(n *Rope) int
+	visit = func(n *Rope) int {
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		id := len(ids)
+		ids[n] = id
+		if n.isLeaf() {
+			fmt.Fprintf(bw, "\tn%d [label=%q];\n", id, fmt.Sprintf("%d %q", n.weight, n.content))
+			return id
+		}
+		fmt.Fprintf(bw, "\tn%d [label=\"%d h%d\" shape=ellipse];\n", id, n.weight, n.height)
+		for i, child := range []*Rope{n.left, n.right} {
+			if child != nil {
+				fmt.Fprintf(bw, "\tn%d -> n%d [label=\"%c\"];\n", id, visit(child), "<>"[i])
+			}
+		}
+		return id
+	}
+	if r != nil {
+		visit(r)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+type jsonNode struct {
+	Weight  int       `json:"weight"`
+	Height  int       `json:"height"`
+	Content *string   `json:"content,omitempty"`
+	Left    *jsonNode `json:"left,omitempty"`
+	Right   *jsonNode `json:"right,omitempty"`
+}
+
+func (r *Rope) jsonNode() *jsonNode {
+	if r == nil {
+		return nil
+	}
+	n := &jsonNode{
+		Weight: r.weight,
+		Height: r.height,
+		Left:   r.left.jsonNode(),
+		Right:  r.right.jsonNode(),
+	}
+	if r.isLeaf() {
+		content := string(r.content)
+		n.Content = &content
+	}
+	return n
+}
+
+// WriteJSON writes the tree as nested JSON objects, invalid UTF-8 in leaves is replaced by U+FFFD
+func (r *Rope) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.jsonNode())
+}
+
+// Retained is the memory retained by a set of rope versions
+type Retained struct {
+	// Nodes is the number of distinct nodes, Leaves the number of distinct non-empty leaves
+	Nodes, Leaves int
+	// NodeBytes is the size of the node structs, not counting the cached summaries
+	NodeBytes int
+	// ContentBytes is the size of the buffers backing the leaves, which can be larger than
+	// their content since leaves split from the same buffer keep all of it alive
+	ContentBytes int
+}
+
+// retention collects the distinct nodes and leaf buffers reachable from ropes
+type retention struct {
+	nodes map[*Rope]bool
+	// buffers maps the start of each leaf buffer to its end, from the capacity
+	buffers map[uintptr]uintptr
+}
+
+func newRetention() *retention {
+	return &retention{
+		nodes:   make(map[*Rope]bool),
+		buffers: make(map[uintptr]uintptr),
+	}
+}
+
+// add walks r, skipping the nodes in skip and the subtrees below them
+func (t *retention) add(r *Rope, skip *retention) {
+	r.iterNodes(func(n *Rope) bool {
+		if t.nodes[n] || skip != nil && skip.nodes[n] {
+			return false
+		}
+		t.nodes[n] = true
+		if len(n.content) > 0 {
+			start := uintptr(unsafe.Pointer(unsafe.SliceData(n.content)))
+			t.buffers[start] = max(t.buffers[start], start+uintptr(cap(n.content)))
+		}
+		return true
+	})
+}
+
+// bufferBytes returns the size of the union of the buffers in sets
+func bufferBytes(sets ...map[uintptr]uintptr) int {
+	type span struct{ start, end uintptr }
+	var spans []span
+	for _, set := range sets {
+		for start, end := range set {
+			spans = append(spans, span{start, end})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	n := 0
+	var covered uintptr // end of the union of the spans before
+	for _, s := range spans {
+		if s.end > covered {
+			n += int(s.end - max(s.start, covered))
+			covered = s.end
+		}
+	}
+	return n
+}
+
+// retained sums the nodes and buffers, leaving out the buffer bytes also retained by skip
+func (t *retention) retained(skip *retention) (ret Retained) {
+	ret.Nodes = len(t.nodes)
+	ret.NodeBytes = ret.Nodes * int(unsafe.Sizeof(Rope{}))
+	for n := range t.nodes {
+		if len(n.content) > 0 {
+			ret.Leaves++
+		}
+	}
+	if skip == nil {
+		ret.ContentBytes = bufferBytes(t.buffers)
+	} else {
+		ret.ContentBytes = bufferBytes(t.buffers, skip.buffers) - bufferBytes(skip.buffers)
+	}
+	return
+}
+
+// SharedBytes returns the memory retained by versions together, nodes and buffers
+// shared between versions are counted once
+func SharedBytes(versions ...*Rope) Retained {
+	t := newRetention()
+	for _, v := range versions {
+		t.add(v, nil)
+	}
+	return t.retained(nil)
+}
+
+// UniqueBytes returns the memory retained by v and by none of others, that is
+// the memory released if v is dropped while others are kept
+func UniqueBytes(v *Rope, others ...*Rope) Retained {
+	o := newRetention()
+	for _, other := range others {
+		o.add(other, nil)
+	}
+	t := newRetention()
+	t.add(v, o)
+	return t.retained(o)
+}
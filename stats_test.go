@@ -0,0 +1,148 @@
+package rope
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestStats(t *testing.T) {
+	var r *Rope
+	if s := r.Stats(); s.Nodes != 0 || s.Bytes != 0 {
+		t.Fatal()
+	}
+	r = NewFromBytes(bytes.Repeat([]byte("foobarbaz"), 100))
+	s := r.Stats()
+	if s.Bytes != 900 || s.Leaves != 113 || s.Nodes != 2*113-1 || s.Depth != r.height {
+		t.Fatalf("%+v", s)
+	}
+	// 112 full leaves of 8 bytes and one of 4
+	if len(s.LeafSizes) != 4 || s.LeafSizes[3] != 112 || s.LeafSizes[2] != 1 {
+		t.Fatalf("%+v", s.LeafSizes)
+	}
+	if s.Fill != 900.0/(113*8) || s.SharedBytes != 0 {
+		t.Fatal()
+	}
+
+	r2 := r.Insert(450, []byte("x"))
+	s = r2.Stats(r)
+	if s.Bytes != 901 || s.SharedBytes < 850 || s.SharedBytes > 900 {
+		t.Fatalf("%+v", s)
+	}
+	if s = r2.Stats(r2); s.SharedBytes != 901 {
+		t.Fatal()
+	}
+	if s = r.Stats(NewFromBytes(r.Bytes())); s.SharedBytes != 0 {
+		t.Fatal()
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	leaf := NewFromBytes([]byte("foo"))
+	r := leaf.Concat(leaf)
+	buf := new(bytes.Buffer)
+	if err := r.WriteDot(buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph rope {") || strings.Count(dot, "label=\"3 \\\"foo\\\"\"") != 1 ||
+		!strings.Contains(dot, "n0 -> n1 [label=\"<\"]") || !strings.Contains(dot, "n0 -> n1 [label=\">\"]") {
+		t.Fatal(dot)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := NewFromBytes([]byte("foobarbaz"))
+	buf := new(bytes.Buffer)
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var n struct {
+		Weight int
+		Height int
+		Left   struct{ Content string }
+		Right  struct{ Content string }
+	}
+	if err := json.Unmarshal(buf.Bytes(), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Weight != 8 || n.Height != 2 || n.Left.Content != "foobarba" || n.Right.Content != "z" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestDump(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := NewFromBytes([]byte("foobarbaz")).Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "8 ||\n  <8 |foobarba|\n  >1 |z|\n" {
+		t.Fatalf("%q", buf.String())
+	}
+}
+
+func TestSharedBytes(t *testing.T) {
+	bs := bytes.Repeat([]byte("foobarbaz"), 100)
+	r := NewFromBytes(bs)
+	all := SharedBytes(r)
+	if all.ContentBytes != len(bs) || all.Leaves != 113 || all.Nodes != 2*113-1 {
+		t.Fatalf("%+v", all)
+	}
+	if all.NodeBytes != all.Nodes*int(unsafe.Sizeof(Rope{})) || SharedBytes(r, r) != all || SharedBytes() != (Retained{}) {
+		t.Fatal()
+	}
+
+	// a left part still retains the whole buffer
+	left, _ := r.Split(100)
+	if SharedBytes(left).ContentBytes != len(bs) || UniqueBytes(left, r).ContentBytes != 0 {
+		t.Fatal()
+	}
+
+	versions := []*Rope{r}
+	for i := 0; i < 10; i++ {
+		versions = append(versions, versions[i].Insert(i*50, []byte("xyz")))
+	}
+	shared := SharedBytes(versions...)
+	if shared.ContentBytes < len(bs) || shared.ContentBytes > len(bs)+10*(3+2*8) {
+		t.Fatalf("%+v", shared)
+	}
+	last := versions[len(versions)-1]
+	unique := UniqueBytes(last, versions[:len(versions)-1]...)
+	if unique.Nodes == 0 || unique.Nodes > 3*last.height || unique.ContentBytes == 0 || unique.ContentBytes > 3+2*8 {
+		t.Fatalf("%+v", unique)
+	}
+	if u := UniqueBytes(last); u != SharedBytes(last) {
+		t.Fatal()
+	}
+	if u := UniqueBytes(r, versions...); u != (Retained{}) {
+		t.Fatal()
+	}
+
+	// leaves allocated from one arena are counted apart
+	arena := make([]byte, 4096)
+	cfg := &Config[byte]{
+		MaxLengthPerNode: 8,
+		Alloc: func(n int) []byte {
+			buf := arena[:n]
+			arena = arena[n:]
+			return buf
+		},
+	}
+	b := NewFromBytesWithConfig(nil, cfg).Builder()
+	for i := 0; i < len(bs); i += 4 {
+		b.Append(bs[i:min(i+4, len(bs))])
+	}
+	r = b.Freeze()
+	if !bytes.Equal(r.Bytes(), bs) || SharedBytes(r).ContentBytes != 113*8 {
+		t.Fatalf("%+v", SharedBytes(r))
+	}
+	_, right := r.Split(450)
+	if u := UniqueBytes(right, r); u.ContentBytes != 0 {
+		t.Fatalf("%+v", u)
+	}
+	if u := UniqueBytes(r, right); u.ContentBytes != 450 {
+		t.Fatalf("%+v", u)
+	}
+}
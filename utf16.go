@@ -0,0 +1,81 @@
+package rope
+
+// utf16Summary counts UTF-16 code units and '\n' separated lines.
+// Units are attributed to the leading byte of each UTF-8 sequence so that
+// leaves may split a rune anywhere.
+type utf16Summary struct {
+	lines int // number of '\n'
+	units int // total code units
+	col   int // code units after the last '\n'
+}
+
+type utf16Measure struct{}
+
+func (utf16Measure) Summarize(bs []byte) (s utf16Summary) {
+	for _, b := range bs {
+		n := utf16Units(b)
+		s.units += n
+		s.col += n
+		if b == '\n' {
+			s.lines++
+			s.col = 0
+		}
+	}
+	return
+}
+
+func (utf16Measure) Combine(a, b utf16Summary) utf16Summary {
+	ret := utf16Summary{
+		lines: a.lines + b.lines,
+		units: a.units + b.units,
+		col:   b.col,
+	}
+	if b.lines == 0 {
+		ret.col += a.col
+	}
+	return ret
+}
+
+// utf16Units returns the code units contributed by a byte of UTF-8.
+// Continuation bytes contribute nothing, 4-bytes sequences encode as a surrogate pair.
+func utf16Units(b byte) int {
+	switch {
+	case b < 0x80:
+		return 1
+	case b < 0xC0:
+		return 0
+	case b < 0xF0:
+		return 1
+	case b < 0xF8:
+		return 2
+	}
+	return 1
+}
+
+// UTF16Len returns the length of the rope in UTF-16 code units
+func (r *Rope) UTF16Len() int {
+	return Summary(r, utf16Measure{}).units
+}
+
+// ByteToUTF16 converts a byte offset to a zero based line and UTF-16 character offset in the line
+func (r *Rope) ByteToUTF16(off int) (line, char int) {
+	s := PrefixSummary(r, utf16Measure{}, off)
+	return s.lines, s.col
+}
+
+// UTF16ToByte converts a zero based line and UTF-16 character offset to a byte offset.
+// A character offset inside a surrogate pair resolves to the start of the pair,
+// offsets past the end of the line resolve to the end of the line.
+func (r *Rope) UTF16ToByte(line, char int) int {
+	off := SeekBy(r, utf16Measure{}, func(s utf16Summary) bool {
+		return s.lines > line || s.lines == line && s.col > char
+	})
+	if off < 0 {
+		return r.Len()
+	}
+	if off == 0 { // negative arguments
+		return 0
+	}
+	// off is just past the leading byte of the first rune beyond the position
+	return off - 1
+}
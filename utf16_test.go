@@ -0,0 +1,44 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestUTF16(t *testing.T) {
+	s := strings.Repeat("foo😀bar\n我能吞\n\n𝄞x\r\nbaz", 16)
+	r := NewFromBytes([]byte(s))
+	if r.UTF16Len() != len(utf16.Encode([]rune(s))) {
+		t.Fatal()
+	}
+	line, char := 0, 0
+	for off, c := range s {
+		l, ch := r.ByteToUTF16(off)
+		if l != line || ch != char {
+			t.Fatalf("%d: got %d %d, expected %d %d", off, l, ch, line, char)
+		}
+		if o := r.UTF16ToByte(line, char); o != off {
+			t.Fatalf("%d %d: got %d, expected %d", line, char, o, off)
+		}
+		if c == '\n' {
+			line++
+			char = 0
+		} else {
+			if n := len(utf16.Encode([]rune{c})); n == 2 {
+				// inside surrogate pair
+				if r.UTF16ToByte(line, char+1) != off {
+					t.Fatal()
+				}
+			}
+			char += len(utf16.Encode([]rune{c}))
+		}
+	}
+	// past line end
+	if r.UTF16ToByte(0, 100) != strings.Index(s, "\n") {
+		t.Fatal()
+	}
+	if r.UTF16ToByte(1000, 0) != len(s) {
+		t.Fatal()
+	}
+}
@@ -1,8 +1,10 @@
 package rope
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -32,17 +34,20 @@ func (r *Rope) StructEqual(r2 *Rope) bool {
 	return true
 }
 
-func (r *Rope) Dump() {
-	r.dump(0, "")
+// Dump writes the tree to w as indented text
+func (r *Rope) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	r.dump(bw, 0, "")
+	return bw.Flush()
 }
 
-func (r *Rope) dump(level int, prefix string) {
-	p("%s%s%d |%s|\n", strings.Repeat("  ", level), prefix, r.weight, r.content)
+func (r *Rope) dump(w io.Writer, level int, prefix string) {
+	fmt.Fprintf(w, "%s%s%d |%s|\n", strings.Repeat("  ", level), prefix, r.weight, r.content)
 	if r.left != nil {
-		r.left.dump(level+1, "<")
+		r.left.dump(w, level+1, "<")
 	}
 	if r.right != nil {
-		r.right.dump(level+1, ">")
+		r.right.dump(w, level+1, ">")
 	}
 }
 
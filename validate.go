@@ -0,0 +1,170 @@
+package rope
+
+import "fmt"
+
+// treeNode gives validateTree access to the nodes of the rope types
+type treeNode[N any] interface {
+	comparable
+	children() (left, right N)
+	// shape returns the weight, height and content length of the node, and the maximum content length
+	shape() (weight, height, length, maxLength int)
+}
+
+func (r *Rope) children() (*Rope, *Rope)                         { return r.left, r.right }
+func (r *RuneRope) children() (*RuneRope, *RuneRope)             { return r.left, r.right }
+func (r *RopeRope) children() (*RopeRope, *RopeRope)             { return r.left, r.right }
+func (r *RopeRuneRope) children() (*RopeRuneRope, *RopeRuneRope) { return r.left, r.right }
+
+func (r *Rope) shape() (int, int, int, int) {
+	return r.weight, r.height, len(r.content), r.config().MaxLengthPerNode
+}
+
+func (r *RuneRope) shape() (int, int, int, int) {
+	return r.weight, r.height, len(r.content), r.config().MaxLengthPerNode
+}
+
+func (r *RopeRope) shape() (int, int, int, int) {
+	return r.weight, r.height, len(r.content), r.config().MaxLengthPerNode
+}
+
+func (r *RopeRuneRope) shape() (int, int, int, int) {
+	return r.weight, r.height, len(r.content), r.config().MaxLengthPerNode
+}
+
+// Validate checks the invariants of the tree and reports the first broken one,
+// with the path of the node from the root as a sequence of < and >
+func (r *Rope) Validate() error {
+	return validateTree(r)
+}
+
+// Validate checks the invariants of the tree and reports the first broken one,
+// with the path of the node from the root as a sequence of < and >
+func (r *RuneRope) Validate() error {
+	return validateTree(r)
+}
+
+// Validate checks the invariants of the tree and reports the first broken one,
+// with the path of the node from the root as a sequence of < and >
+func (r *RopeRope) Validate() error {
+	return validateTree(r)
+}
+
+// Validate checks the invariants of the tree and reports the first broken one,
+// with the path of the node from the root as a sequence of < and >
+func (r *RopeRuneRope) Validate() error {
+	return validateTree(r)
+}
+
+// validateTree checks the tree rooted at r, which may be nil or an empty leaf
+func validateTree[N treeNode[N]](r N) error {
+	var none N
+	if r == none {
+		return nil
+	}
+	left, right := r.children()
+	if weight, height, length, _ := r.shape(); left == none && right == none && length == 0 {
+		if height != 0 || weight != 0 {
+			return fmt.Errorf("rope: node root: empty leaf with height %d, weight %d", height, weight)
+		}
+		return nil
+	}
+	_, err := validateNode(r, "")
+	return err
+}
+
+// validateNode checks the subtree rooted at r, returning its length
+func validateNode[N treeNode[N]](r N, path string) (int, error) {
+	var none N
+	if r == none {
+		return 0, fmt.Errorf("rope: node %s: missing child", nodePath(path))
+	}
+	left, right := r.children()
+	weight, height, length, maxLength := r.shape()
+	if left == none && right == none {
+		switch {
+		case length == 0:
+			return 0, fmt.Errorf("rope: node %s: empty leaf", nodePath(path))
+		case weight != length:
+			return 0, fmt.Errorf("rope: node %s: weight %d, content length %d", nodePath(path), weight, length)
+		case height != 1:
+			return 0, fmt.Errorf("rope: node %s: leaf height %d", nodePath(path), height)
+		case length > maxLength:
+			return 0, fmt.Errorf("rope: node %s: content length %d over %d", nodePath(path), length, maxLength)
+		}
+		return length, nil
+	}
+	if length > 0 {
+		return 0, fmt.Errorf("rope: node %s: content length %d in an internal node", nodePath(path), length)
+	}
+	l, err := validateNode(left, path+"<")
+	if err != nil {
+		return 0, err
+	}
+	rl, err := validateNode(right, path+">")
+	if err != nil {
+		return 0, err
+	}
+	_, leftHeight, _, _ := left.shape()
+	_, rightHeight, _, _ := right.shape()
+	return l + rl, checkNode(path, weight, l, height, leftHeight, rightHeight)
+}
+
+// checkNode checks the weight and the height of an internal node against its children
+func checkNode(path string, weight, leftLen, height, leftHeight, rightHeight int) error {
+	switch {
+	case weight != leftLen:
+		return fmt.Errorf("rope: node %s: weight %d, left length %d", nodePath(path), weight, leftLen)
+	case height != max(leftHeight, rightHeight)+1:
+		return fmt.Errorf("rope: node %s: height %d, children heights %d and %d", nodePath(path), height, leftHeight, rightHeight)
+	case leftHeight-rightHeight > 1 || rightHeight-leftHeight > 1:
+		return fmt.Errorf("rope: node %s: unbalanced, children heights %d and %d", nodePath(path), leftHeight, rightHeight)
+	}
+	return nil
+}
+
+func nodePath(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return path
+}
+
+// mustValidate panics if r is invalid when built with the ropedebug tag
+func (r *Rope) mustValidate() *Rope {
+	if debug {
+		if err := r.Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// mustValidate panics if r is invalid when built with the ropedebug tag
+func (r *RuneRope) mustValidate() *RuneRope {
+	if debug {
+		if err := r.Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// mustValidate panics if r is invalid when built with the ropedebug tag
+func (r *RopeRope) mustValidate() *RopeRope {
+	if debug {
+		if err := r.Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// mustValidate panics if r is invalid when built with the ropedebug tag
+func (r *RopeRuneRope) mustValidate() *RopeRuneRope {
+	if debug {
+		if err := r.Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
@@ -0,0 +1,81 @@
+package rope
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var r *Rope
+	if r.Validate() != nil || NewFromBytes(nil).Validate() != nil {
+		t.Fatal()
+	}
+	r = NewFromBytes(bytes.Repeat([]byte("foobarbaz"), 32))
+	if r.Validate() != nil {
+		t.Fatal()
+	}
+
+	// corrupt a copy of the rightmost internal node
+	corrupt := func(fn func(n *Rope)) *Rope {
+		root := *r
+		right := *root.right
+		root.right = &right
+		fn(&right)
+		return &root
+	}
+	if err := corrupt(func(n *Rope) { n.weight++ }).Validate(); err == nil ||
+		!strings.Contains(err.Error(), "node >: weight") {
+		t.Fatal(err)
+	}
+	if err := corrupt(func(n *Rope) { n.height++ }).Validate(); err == nil ||
+		!strings.Contains(err.Error(), "node >: height") {
+		t.Fatal(err)
+	}
+	if err := corrupt(func(n *Rope) { n.right = nil }).Validate(); err == nil ||
+		!strings.Contains(err.Error(), "node >>: missing child") {
+		t.Fatal(err)
+	}
+	if err := corrupt(func(n *Rope) { n.left = n.left.left }).Validate(); err == nil {
+		t.Fatal()
+	}
+
+	// unbalanced but consistent
+	leaf := func(s string) *Rope {
+		return NewFromBytes([]byte(s))
+	}
+	deep := &Rope{height: 3, weight: 2, left: &Rope{height: 2, weight: 1, left: leaf("a"), right: leaf("b")}, right: leaf("c")}
+	if deep.Validate() != nil {
+		t.Fatal()
+	}
+	deep = &Rope{height: 4, weight: 3, left: deep, right: leaf("d")}
+	if err := deep.Validate(); err == nil || !strings.Contains(err.Error(), "node root: unbalanced") {
+		t.Fatal(err)
+	}
+
+	// oversized leaf
+	big := &Rope{height: 1, weight: 9, content: []byte("foobarbaz")}
+	if err := big.Validate(); err == nil || !strings.Contains(err.Error(), "node root: content length 9 over 8") {
+		t.Fatal(err)
+	}
+
+	rr := NewFromRunes([]rune(strings.Repeat("foobarbaz", 100)))
+	if rr.Validate() != nil {
+		t.Fatal()
+	}
+	rr.left.weight++
+	if err := rr.Validate(); err == nil || !strings.Contains(err.Error(), "node <: weight") {
+		t.Fatal(err)
+	}
+
+	row := *NewFromBytes([]byte("row"))
+	rows := NewFromRope(make([]Rope, 2000))
+	rows = rows.Insert(1000, []Rope{row})
+	if rows.Validate() != nil {
+		t.Fatal()
+	}
+	rows.height++
+	if err := rows.Validate(); err == nil || !strings.Contains(err.Error(), "node root: height") {
+		t.Fatal(err)
+	}
+}
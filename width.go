@@ -0,0 +1,336 @@
+package rope
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// runeWidth returns the number of terminal cells occupied by c, not counting tabs
+func runeWidth(c rune) int {
+	switch {
+	case c < 0x20, c >= 0x7F && c < 0xA0:
+		return 0
+	case c < 0x7F:
+		return 1
+	case unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case c >= 0x1160 && c <= 0x11FF: // Hangul medial vowels and final consonants
+		return 0
+	case unicode.Is(eastAsianWide, c):
+		return 2
+	}
+	return 1
+}
+
+func tabStop(c, tabWidth int) int {
+	return c - c%tabWidth + tabWidth
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// colFn maps the column before a piece of text to the column after it:
+// c -> tabStop(c+a)+b if tab is set, c+a otherwise, with c taken as 0 if reset is set
+type colFn struct {
+	reset bool
+	tab   bool
+	a, b  int
+}
+
+// then composes f with g, g applied after f
+func (f colFn) then(g colFn, tabWidth int) colFn {
+	switch {
+	case g.reset:
+		return g
+	case !f.tab && !g.tab:
+		return colFn{f.reset, false, f.a + g.a, 0}
+	case !f.tab:
+		return colFn{f.reset, true, f.a + g.a, g.b}
+	case !g.tab:
+		return colFn{f.reset, true, f.a, f.b + g.a}
+	}
+	// tabStop(c+a) is a multiple of tabWidth
+	return colFn{f.reset, true, f.a, tabStop(f.b+g.a, tabWidth) + g.b}
+}
+
+func (f colFn) apply(c, tabWidth int) int {
+	if f.reset {
+		c = 0
+	}
+	if f.tab {
+		return tabStop(c+f.a, tabWidth) + f.b
+	}
+	return c + f.a
+}
+
+// runeCol returns the column transform of c
+func runeCol(c rune) colFn {
+	if c == '\t' {
+		return colFn{tab: true}
+	}
+	return colFn{a: runeWidth(c)}
+}
+
+// rowFn is the column transform of a piece of a line laid out in rows: col, plus push[c%len(push)]
+// empty cells left by the wide runes moved to the next row. push is nil if there are none.
+// Since tab stops and row ends repeat, so does push, with a period dividing lcm(tabWidth, wrapWidth).
+type rowFn struct {
+	col  colFn
+	push []int32
+}
+
+// widthSummary tracks display columns and soft wrapped rows. Runes split across leaves
+// are kept as raw bytes until both parts are combined.
+type widthSummary struct {
+	n     int    // bytes
+	lines int    // number of '\n'
+	pre   string // leading continuation bytes of a rune started before
+	head  rowFn  // column transform of the runes before the first '\n'
+	rows  int    // rows of the lines ending after the first '\n'
+	fn    rowFn  // column transform of the runes after the last '\n'
+	post  string // trailing incomplete rune
+}
+
+// widthMeasure computes display columns, and rows when wrapWidth is positive
+type widthMeasure struct {
+	tabWidth  int
+	wrapWidth int
+}
+
+// lineRows returns the number of rows of a line with display width w
+func (m widthMeasure) lineRows(w int) int {
+	if m.wrapWidth < 1 || w == 0 {
+		return 1
+	}
+	return (w + m.wrapWidth - 1) / m.wrapWidth
+}
+
+// advance returns the column after c laid out at col, a wide rune that would cross the end
+// of a row starting the next one
+func (m widthMeasure) advance(col int, c rune) int {
+	if c == '\t' {
+		return tabStop(col, m.tabWidth)
+	}
+	w := runeWidth(c)
+	if w > 1 && m.wrapWidth > 1 && col%m.wrapWidth == m.wrapWidth-1 {
+		col++
+	}
+	return col + w
+}
+
+func (m widthMeasure) apply(f rowFn, c int) int {
+	ret := f.col.apply(c, m.tabWidth)
+	if f.push != nil {
+		ret += int(f.push[c%len(f.push)])
+	}
+	return ret
+}
+
+// period returns a p such that f(c+p) = f(c)+p
+func (m widthMeasure) period(f rowFn) int {
+	switch {
+	case f.push != nil:
+		return len(f.push)
+	case f.col.tab:
+		return m.tabWidth
+	}
+	return 1
+}
+
+// then composes f with g, g applied after f
+func (m widthMeasure) then(f, g rowFn) rowFn {
+	h := rowFn{col: f.col.then(g.col, m.tabWidth)}
+	switch {
+	case f.push == nil && g.push == nil:
+		return h
+	case h.col.reset:
+		return rowFn{col: colFn{reset: true, a: m.apply(g, m.apply(f, 0))}}
+	}
+	push := make([]int32, lcm(m.period(f), m.period(g)))
+	return m.withPush(h, push, func(c int) int {
+		return m.apply(g, m.apply(f, c))
+	})
+}
+
+// withPush returns f with the push table filled from the columns after the piece laid out
+// from each column below len(push), nil if no wide rune moves
+func (m widthMeasure) withPush(f rowFn, push []int32, after func(c int) int) rowFn {
+	f.push = nil
+	for c := range push {
+		push[c] = int32(after(c) - f.col.apply(c, m.tabWidth))
+		if push[c] != 0 {
+			f.push = push
+		}
+	}
+	return f
+}
+
+// headFn returns the column transform of the runes in bs, which hold no '\n'
+func (m widthMeasure) headFn(bs []byte) rowFn {
+	var f rowFn
+	// steps lists the narrow runs as their width, tabs as -1 and wide runes as minus their width
+	var steps []int
+	narrow, wide := 0, false
+	for _, c := range string(bs) {
+		f.col = f.col.then(runeCol(c), m.tabWidth)
+		if w := runeWidth(c); c != '\t' && w < 2 {
+			narrow += w
+		} else {
+			if c == '\t' {
+				w = 1
+			}
+			wide = wide || w > 1
+			steps = append(steps, narrow, -w)
+			narrow = 0
+		}
+	}
+	if !wide || m.wrapWidth < 2 {
+		return f
+	}
+	p := m.wrapWidth
+	if f.col.tab {
+		p = lcm(p, m.tabWidth)
+	}
+	steps = append(steps, narrow)
+	return m.withPush(f, make([]int32, p), func(col int) int {
+		for _, w := range steps {
+			switch {
+			case w >= 0:
+				col += w
+			case w == -1:
+				col = tabStop(col, m.tabWidth)
+			case col%m.wrapWidth == m.wrapWidth-1:
+				col += 1 - w
+			default:
+				col -= w
+			}
+		}
+		return col
+	})
+}
+
+func (m widthMeasure) addRune(s *widthSummary, c rune) {
+	switch {
+	case c == '\n':
+		if s.lines == 0 {
+			s.head = s.fn
+		} else {
+			s.rows += m.lineRows(m.apply(s.fn, 0))
+		}
+		s.lines++
+		s.fn = rowFn{col: colFn{reset: true}}
+	case s.fn.col.reset:
+		s.fn = rowFn{col: colFn{reset: true, a: m.advance(m.apply(s.fn, 0), c)}}
+	default:
+		s.fn = m.then(s.fn, m.headFn([]byte(string(c))))
+	}
+}
+
+func (m widthMeasure) Summarize(bs []byte) (s widthSummary) {
+	s.n = len(bs)
+	i := 0
+	for i < len(bs) && i < utf8.UTFMax-1 && !utf8.RuneStart(bs[i]) {
+		i++
+	}
+	s.pre = string(bs[:i])
+	bs = bs[i:]
+	// the runes before the first '\n' are laid out from every starting column at once
+	j := 0
+	for j < len(bs) && bs[j] != '\n' && utf8.FullRune(bs[j:]) {
+		_, l := utf8.DecodeRune(bs[j:])
+		j += l
+	}
+	s.fn = m.headFn(bs[:j])
+	bs = bs[j:]
+	for len(bs) > 0 {
+		if !utf8.FullRune(bs) {
+			s.post = string(bs)
+			break
+		}
+		c, l := utf8.DecodeRune(bs)
+		m.addRune(&s, c)
+		bs = bs[l:]
+	}
+	return
+}
+
+func (m widthMeasure) Combine(a, b widthSummary) widthSummary {
+	if a.n == 0 {
+		return b
+	}
+	if b.n == 0 {
+		return a
+	}
+	if a.n == len(a.pre) { // a only continues a rune started before
+		b.n += a.n
+		b.pre = a.pre + b.pre
+		return b
+	}
+	ret := a
+	ret.n += b.n
+	ret.post = ""
+	mid := a.post + b.pre
+	for len(mid) > 0 {
+		if b.n == len(b.pre) && !utf8.FullRuneInString(mid) { // still incomplete
+			ret.post = mid
+			return ret
+		}
+		c, l := utf8.DecodeRuneInString(mid)
+		m.addRune(&ret, c)
+		mid = mid[l:]
+	}
+	if b.lines == 0 {
+		ret.fn = m.then(ret.fn, b.fn)
+	} else {
+		if ret.lines == 0 {
+			ret.head = m.then(ret.fn, b.head)
+		} else {
+			ret.rows += m.lineRows(m.apply(m.then(ret.fn, b.head), 0))
+		}
+		ret.rows += b.rows
+		ret.lines += b.lines
+		ret.fn = b.fn
+	}
+	ret.post = b.post
+	return ret
+}
+
+func newWidthMeasure(tabWidth, wrapWidth int) widthMeasure {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	if wrapWidth < 0 {
+		wrapWidth = 0
+	}
+	return widthMeasure{tabWidth, wrapWidth}
+}
+
+// VisualColumn returns the display column of offset in its line, counting wide characters
+// as two cells, combining marks as none and expanding tabs to multiples of tabWidth
+func (r *Rope) VisualColumn(offset, tabWidth int) int {
+	m := newWidthMeasure(tabWidth, 0)
+	return m.apply(PrefixSummary(r, m, offset).fn, 0)
+}
+
+// OffsetForVisualColumn returns the offset of the rune covering display column col of the zero based line.
+// Columns past the end of the line resolve to the end of the line.
+func (r *Rope) OffsetForVisualColumn(line, col, tabWidth int) int {
+	m := newWidthMeasure(tabWidth, 0)
+	off := SeekBy(r, m, func(s widthSummary) bool {
+		return s.lines > line || s.lines == line && m.apply(s.fn, 0) > col
+	})
+	if off < 0 {
+		return r.Len()
+	}
+	if off == 0 { // negative arguments
+		return 0
+	}
+	// off is just past the first rune ending beyond col
+	_, size := r.runeBefore(off)
+	return off - size
+}
@@ -0,0 +1,55 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVisualColumn(t *testing.T) {
+	s := strings.Repeat("a\tb我能\téx\n\t\t😀z\n\nfoo\tbarbaz\t1\n", 8)
+	r := NewFromBytes([]byte(s))
+	for _, tabWidth := range []int{1, 4, 8} {
+		line, col := 0, 0
+		lineStarts := []int{0}
+		for off, c := range s {
+			if got := r.VisualColumn(off, tabWidth); got != col {
+				t.Fatalf("%d %d: got %d expected %d", tabWidth, off, got, col)
+			}
+			switch c {
+			case '\n':
+				line++
+				col = 0
+				lineStarts = append(lineStarts, off+1)
+			case '\t':
+				col = (col/tabWidth + 1) * tabWidth
+			default:
+				col += runeWidth(c)
+			}
+		}
+
+		for l, start := range lineStarts {
+			end := strings.IndexByte(s[start:], '\n')
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += start
+			}
+			col := 0
+			for i, c := range s[start:end] {
+				off := start + i
+				next := col + runeWidth(c)
+				if c == '\t' {
+					next = (col/tabWidth + 1) * tabWidth
+				}
+				for ; col < next; col++ {
+					if got := r.OffsetForVisualColumn(l, col, tabWidth); got != off {
+						t.Fatalf("%d %d %d: got %d expected %d", tabWidth, l, col, got, off)
+					}
+				}
+			}
+			if r.OffsetForVisualColumn(l, col+10, tabWidth) != end {
+				t.Fatal()
+			}
+		}
+	}
+}
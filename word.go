@@ -0,0 +1,144 @@
+package rope
+
+// Word segmentation per UAX #29
+
+const (
+	wbOther = iota
+	wbCR
+	wbLF
+	wbNewline
+	wbExtend
+	wbZWJ
+	wbRI
+	wbFormat
+	wbKatakana
+	wbHebrewLetter
+	wbALetter
+	wbSingleQuote
+	wbDoubleQuote
+	wbMidNumLet
+	wbMidLetter
+	wbMidNum
+	wbNumeric
+	wbExtendNumLet
+	wbWSegSpace
+	wbExtPict
+	wbALetterExtPict // ALetter that is also Extended_Pictographic
+)
+
+func wordProperty(c rune) int {
+	return lookupProp(wordBreakRanges, c)
+}
+
+func wordIgnored(p int) bool {
+	return p == wbExtend || p == wbFormat || p == wbZWJ
+}
+
+func wordAHLetter(p int) bool {
+	return p == wbALetter || p == wbALetterExtPict || p == wbHebrewLetter
+}
+
+func wordMidLetterQ(p int) bool {
+	return p == wbMidLetter || p == wbMidNumLet || p == wbSingleQuote
+}
+
+func wordMidNumQ(p int) bool {
+	return p == wbMidNum || p == wbMidNumLet || p == wbSingleQuote
+}
+
+func wordBreak(props []int, i int) bool {
+	a, b := props[i-1], props[i]
+	switch {
+	case a == wbCR && b == wbLF: // WB3
+		return false
+	case a == wbNewline || a == wbCR || a == wbLF: // WB3a
+		return true
+	case b == wbNewline || b == wbCR || b == wbLF: // WB3b
+		return true
+	case a == wbZWJ && (b == wbExtPict || b == wbALetterExtPict): // WB3c
+		return false
+	case a == wbWSegSpace && b == wbWSegSpace: // WB3d
+		return false
+	case wordIgnored(b): // WB4
+		return false
+	}
+	// WB4, ignore Extend, Format and ZWJ unless they follow a line break or the start of text
+	ia := prevNonIgnored(props, i, wordIgnored)
+	if ia < 0 || props[ia] == wbNewline || props[ia] == wbCR || props[ia] == wbLF {
+		ia = i - 1
+	}
+	a = props[ia]
+	before, after := -1, -1
+	if j := prevNonIgnored(props, ia, wordIgnored); j >= 0 {
+		before = props[j]
+	}
+	if j := nextNonIgnored(props, i, wordIgnored); j < len(props) {
+		after = props[j]
+	}
+	switch {
+	case wordAHLetter(a) && wordAHLetter(b): // WB5
+		return false
+	case wordAHLetter(a) && wordMidLetterQ(b) && wordAHLetter(after): // WB6
+		return false
+	case wordAHLetter(before) && wordMidLetterQ(a) && wordAHLetter(b): // WB7
+		return false
+	case a == wbHebrewLetter && b == wbSingleQuote: // WB7a
+		return false
+	case a == wbHebrewLetter && b == wbDoubleQuote && after == wbHebrewLetter: // WB7b
+		return false
+	case before == wbHebrewLetter && a == wbDoubleQuote && b == wbHebrewLetter: // WB7c
+		return false
+	case a == wbNumeric && b == wbNumeric: // WB8
+		return false
+	case wordAHLetter(a) && b == wbNumeric: // WB9
+		return false
+	case a == wbNumeric && wordAHLetter(b): // WB10
+		return false
+	case before == wbNumeric && wordMidNumQ(a) && b == wbNumeric: // WB11
+		return false
+	case a == wbNumeric && wordMidNumQ(b) && after == wbNumeric: // WB12
+		return false
+	case a == wbKatakana && b == wbKatakana: // WB13
+		return false
+	case (wordAHLetter(a) || a == wbNumeric || a == wbKatakana || a == wbExtendNumLet) && b == wbExtendNumLet: // WB13a
+		return false
+	case a == wbExtendNumLet && (wordAHLetter(b) || b == wbNumeric || b == wbKatakana): // WB13b
+		return false
+	case a == wbRI && b == wbRI: // WB15, WB16
+		n := 0
+		for j := ia; j >= 0 && props[j] == wbRI; j = prevNonIgnored(props, j, wordIgnored) {
+			n++
+		}
+		return n%2 == 0
+	}
+	return true // WB999
+}
+
+// IterWordBoundaries calls fn with each word boundary after offset in increasing order
+func (r *Rope) IterWordBoundaries(offset int, fn func(int) bool) {
+	r.iterBoundaries(offset, wordProperty, wordBreak, fn)
+}
+
+// IterWordBoundariesBackward calls fn with each word boundary before offset in decreasing order
+func (r *Rope) IterWordBoundariesBackward(offset int, fn func(int) bool) {
+	r.iterBoundariesBackward(offset, wordProperty, wordBreak, fn)
+}
+
+// NextWordBoundary returns the first word boundary after offset, or Len
+func (r *Rope) NextWordBoundary(offset int) (ret int) {
+	ret = r.Len()
+	r.IterWordBoundaries(offset, func(i int) bool {
+		ret = i
+		return false
+	})
+	return
+}
+
+// PrevWordBoundary returns the last word boundary before offset, or 0
+func (r *Rope) PrevWordBoundary(offset int) (ret int) {
+	r.IterWordBoundariesBackward(offset, func(i int) bool {
+		ret = i
+		return false
+	})
+	return
+}
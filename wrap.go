@@ -0,0 +1,91 @@
+package rope
+
+// Soft wrap layout. A line of display width w occupies max(1, ceil(w/width)) visual rows, a wide
+// rune that would cross the end of a row starting the next one. The summaries carry the cells left
+// empty that way by starting column, so rows are counted from the cached summaries like columns.
+
+// endedRows returns the rows of the lines ended in s
+func (m widthMeasure) endedRows(s widthSummary) int {
+	if s.lines == 0 {
+		return 0
+	}
+	return m.lineRows(m.apply(s.head, 0)) + s.rows
+}
+
+// column returns the column at the end of s
+func (m widthMeasure) column(s widthSummary) int {
+	return m.apply(s.fn, 0)
+}
+
+// lineStart returns the offset of the zero based line, lines ending at '\n' only
+func (m widthMeasure) lineStart(r *Rope, line int) int {
+	if line <= 0 {
+		return 0
+	}
+	return SeekBy(r, m, func(s widthSummary) bool {
+		return s.lines >= line
+	})
+}
+
+// VisualRowCount returns the number of visual rows when wrapping lines at width cells.
+// A width less than 1 disables wrapping.
+func (r *Rope) VisualRowCount(width, tabWidth int) int {
+	m := newWidthMeasure(tabWidth, width)
+	s := Summary(r, m)
+	return m.endedRows(s) + m.lineRows(m.column(s))
+}
+
+// VisualRow returns the visual row of offset when wrapping lines at width cells
+func (r *Rope) VisualRow(offset, width, tabWidth int) int {
+	m := newWidthMeasure(tabWidth, width)
+	s := PrefixSummary(r, m, offset)
+	row := m.endedRows(s)
+	if m.wrapWidth > 0 {
+		col := m.column(s)
+		c, size := r.runeAt(offset)
+		if m.wrapWidth > 1 && runeWidth(c) > 1 && col%m.wrapWidth == m.wrapWidth-1 {
+			col++ // a wide rune moved to the next row starts there
+		}
+		row += col / m.wrapWidth
+		if col > 0 && col%m.wrapWidth == 0 {
+			// the end of a row is not the start of the next one if the line ends there
+			if size == 0 || c == '\n' {
+				row--
+			}
+		}
+	}
+	return row
+}
+
+// OffsetForVisualRow returns the offset of the first rune of a visual row when wrapping lines at width cells.
+// Rows past the end resolve to Len.
+func (r *Rope) OffsetForVisualRow(row, width, tabWidth int) int {
+	if row <= 0 {
+		return 0
+	}
+	m := newWidthMeasure(tabWidth, width)
+	// find the line containing row
+	end := SeekBy(r, m, func(s widthSummary) bool {
+		return m.endedRows(s) > row
+	})
+	var s widthSummary
+	if end < 0 { // the last line
+		s = Summary(r, m)
+	} else { // the line ended by the '\n' before end
+		s = PrefixSummary(r, m, end-1)
+	}
+	row -= m.endedRows(s)
+	if row >= m.lineRows(m.column(s)) {
+		return r.Len()
+	}
+	if row == 0 {
+		return m.lineStart(r, s.lines)
+	}
+	// the first rune ending past the start of the row
+	line, target := s.lines, row*m.wrapWidth
+	off := SeekBy(r, m, func(s widthSummary) bool {
+		return s.lines > line || s.lines == line && m.column(s) > target
+	})
+	_, size := r.runeBefore(off)
+	return off - size
+}
@@ -0,0 +1,128 @@
+package rope
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// wrapModel lays out line in rows of width cells, returning the offset and the columns
+// before and after each rune, a wide rune crossing the end of a row starting the next one
+func wrapModel(line string, width, tabWidth int) (offs, from, to []int) {
+	col := 0
+	for i, c := range line {
+		start, w := col, runeWidth(c)
+		if c == '\t' {
+			w = tabStop(col, tabWidth) - col
+		} else if w > 1 && width > 1 && col%width == width-1 {
+			start++
+		}
+		col = start + w
+		offs, from, to = append(offs, i), append(from, start), append(to, col)
+	}
+	return
+}
+
+// testVisualRows checks the rows of r holding s against wrapModel
+func testVisualRows(t *testing.T, r *Rope, s string, width, tabWidth int) {
+	lines := strings.Split(s, "\n")
+	row := 0
+	start := 0
+	for l, line := range lines {
+		offs, from, to := wrapModel(line, width, tabWidth)
+		rows := 1
+		if width > 0 && len(to) > 0 && to[len(to)-1] > 0 {
+			rows = (to[len(to)-1] + width - 1) / width
+		}
+		i := 0
+		for k := 0; k < rows; k++ {
+			// the first rune ending past the start of the row
+			for k > 0 && to[i] <= k*width {
+				i++
+			}
+			expected := start
+			if k > 0 {
+				expected += offs[i]
+			}
+			if got := r.OffsetForVisualRow(row+k, width, tabWidth); got != expected {
+				t.Fatalf("%d %d: got %d expected %d", width, row+k, got, expected)
+			}
+			// runes spanning several rows belong to the first one
+			expectedRow := row
+			if width > 0 && len(from) > 0 {
+				expectedRow += from[i] / width
+			}
+			if got := r.VisualRow(expected, width, tabWidth); got != expectedRow {
+				t.Fatalf("%d %d: got %d expected %d", width, expected, got, expectedRow)
+			}
+		}
+		if got := r.VisualRow(start+len(line), width, tabWidth); got != row+rows-1 {
+			t.Fatalf("%d %d: got %d expected %d", width, l, got, row+rows-1)
+		}
+		row += rows
+		start += len(line) + 1
+	}
+	if got := r.VisualRowCount(width, tabWidth); got != row {
+		t.Fatalf("%d: got %d expected %d", width, got, row)
+	}
+	if r.OffsetForVisualRow(row, width, tabWidth) != len(s) {
+		t.Fatal()
+	}
+}
+
+func TestVisualRows(t *testing.T) {
+	s := strings.Repeat("a\tb我能\téx\n\n\t\t😀z foobarbazfoobarbaz\nshort\nfoo\tbarbazbarbazbarbazbarbaz\t1\n我我我a我我", 4)
+	r := NewFromBytes([]byte(s))
+	for _, width := range []int{0, 1, 3, 7, 10, 80} {
+		pos := len(s) / 2
+		for !utf8.RuneStart(s[pos]) {
+			pos--
+		}
+		r = r.Insert(pos, []byte("我能吞\t"))
+		s = s[:pos] + "我能吞\t" + s[pos:]
+		testVisualRows(t, r, s, width, 4)
+	}
+	if NewFromBytes(nil).VisualRowCount(80, 4) != 1 {
+		t.Fatal()
+	}
+}
+
+func TestVisualRowsLongLines(t *testing.T) {
+	// lines with wide runes and tabs spanning many small leaves
+	rnd := rand.New(rand.NewSource(1))
+	pieces := []string{"a", "我", "\t", "é", "\u0301", "😀", "\n", "xy"}
+	for n := 0; n < 20; n++ {
+		var b strings.Builder
+		for i := rnd.Intn(300); i > 0; i-- {
+			p := pieces[rnd.Intn(len(pieces))]
+			if p == "\n" && rnd.Intn(4) > 0 {
+				p = "我"
+			}
+			b.WriteString(p)
+		}
+		s := b.String()
+		r := NewFromBytesWithConfig([]byte(s), &Config[byte]{MaxLengthPerNode: 1 + rnd.Intn(8)})
+		for _, width := range []int{0, 1, 2, 3, 5, 8, 12} {
+			testVisualRows(t, r, s, width, 1+rnd.Intn(5))
+		}
+	}
+}
+
+func TestVisualRowsWideBoundary(t *testing.T) {
+	// the third 我 would cross the end of the second row
+	r := NewFromBytes([]byte("我我我\nx"))
+	if r.VisualRowCount(3, 4) != 4 {
+		t.Fatal()
+	}
+	for row, off := range []int{0, 3, 6, 10, 11} {
+		if r.OffsetForVisualRow(row, 3, 4) != off {
+			t.Fatal(row)
+		}
+	}
+	for off, row := range map[int]int{0: 0, 3: 1, 6: 2, 9: 2, 10: 3, 11: 3} {
+		if r.VisualRow(off, 3, 4) != row {
+			t.Fatal(off)
+		}
+	}
+}